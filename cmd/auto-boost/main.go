@@ -17,6 +17,7 @@ import (
 
 	utils "github.com/thnxdev/utils"
 	"github.com/thnxdev/utils/utils/config"
+	"github.com/thnxdev/utils/utils/httpgh"
 	"github.com/thnxdev/utils/utils/log"
 )
 
@@ -99,7 +100,9 @@ func run(
 ) error {
 	logger := log.FromContext(ctx)
 
-	gclient := github.NewClient(nil).WithAuthToken(string(ghAccesstoken))
+	gclient := github.NewClient(
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	).WithAuthToken(string(ghAccesstoken))
 
 	for nextPage := 0; ; {
 		repos, resp, err := gclient.Repositories.List(ctx, "", &github.RepositoryListOptions{
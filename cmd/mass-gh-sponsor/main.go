@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // --timezone works without system tzdata
 
 	errors "github.com/alecthomas/errors"
 	"github.com/alecthomas/kong"
@@ -14,11 +15,21 @@ import (
 	"github.com/thnxdev/utils/database"
 	"github.com/thnxdev/utils/utils/config"
 	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
 
+	animatelocal "github.com/thnxdev/utils/commands/animate-local"
 	animaterepos "github.com/thnxdev/utils/commands/animate-repos"
 	dlrepos "github.com/thnxdev/utils/commands/dl-repos"
 	"github.com/thnxdev/utils/commands/donate"
-	importcsv "github.com/thnxdev/utils/commands/import-csv"
+	"github.com/thnxdev/utils/commands/history"
+	importdonations "github.com/thnxdev/utils/commands/import-donations"
+	importsbom "github.com/thnxdev/utils/commands/import-sbom"
+	policycmd "github.com/thnxdev/utils/commands/policy"
+	"github.com/thnxdev/utils/commands/prune"
+	"github.com/thnxdev/utils/commands/reconcile"
+	"github.com/thnxdev/utils/commands/run"
+	sponsordefaults "github.com/thnxdev/utils/commands/sponsor-defaults"
+	"github.com/thnxdev/utils/commands/status"
 )
 
 // Populated during build.
@@ -31,12 +42,22 @@ var cli struct {
 	LogLevel logrus.Level `help:"Log level (${enum})." default:"info" enum:"trace,debug,info,warning,error,fatal,panic" group:"Observability:"`
 	LogJSON  bool         `help:"Log in JSON format." group:"Observability:"`
 
-	DbPath string `help:"Path to db file." required:"" env:"DB_PATH" default:"db.sql"`
-
-	ImportCsv    importcsv.CmdImportCsv       `cmd:"" help:"Import list of donations from csv file."`
-	DlRepos      dlrepos.CmdDlRepos           `cmd:"" help:"Import the user's github repos."`
-	AnimateRepos animaterepos.CmdAnimateRepos `cmd:"" help:"Animate the sponsorable dependencies for each repo."`
-	Donate       donate.CmdDonate             `cmd:"" help:"Create the require GitHub sponsorships."`
+	DbPath     string `help:"Path to db file." required:"" env:"DB_PATH" default:"db.sql"`
+	PolicyPath string `help:"Path to the recipient policy file." env:"POLICY_PATH" default:"policy.json"`
+
+	Import          importdonations.CmdImport          `cmd:"" aliases:"import-csv" help:"Import list of donations from a csv, json, yaml or ndjson file."`
+	ImportSbom      importsbom.CmdImportSbom           `cmd:"" help:"Import the sponsorable dependencies listed in SPDX or CycloneDX SBOMs."`
+	DlRepos         dlrepos.CmdDlRepos                 `cmd:"" help:"Import the github repos of the entities."`
+	AnimateRepos    animaterepos.CmdAnimateRepos       `cmd:"" help:"Animate the sponsorable dependencies for each repo."`
+	AnimateLocal    animatelocal.CmdAnimateLocal       `cmd:"" help:"Animate the sponsorable dependencies of a checked out repo from its manifests."`
+	Donate          donate.CmdDonate                   `cmd:"" help:"Create the require GitHub sponsorships."`
+	Reconcile       reconcile.CmdReconcile             `cmd:"" help:"Sync existing GitHub sponsorships into the db."`
+	Prune           prune.CmdPrune                     `cmd:"" help:"Cancel recurring sponsorships of dependencies no longer used."`
+	History         history.CmdHistory                 `cmd:"" help:"List the donations made and attempted."`
+	Status          status.CmdStatus                   `cmd:"" help:"Summarise the repos and donations in the db."`
+	Policy          policycmd.CmdPolicy                `cmd:"" help:"Manage which recipients may be sponsored."`
+	SponsorDefaults sponsordefaults.CmdSponsorDefaults `cmd:"" help:"Set the default privacy and email preferences of a sponsor."`
+	Run             run.CmdRun                         `cmd:"" aliases:"daemon" help:"Run dl-repos, animate-repos and donate on intervals until stopped."`
 }
 
 func main() {
@@ -84,8 +105,11 @@ func main() {
 	db, err := database.Open(ctx, cli.DbPath)
 	kctx.FatalIfErrorf(err)
 
+	pol, err := policy.Load(cli.PolicyPath)
+	kctx.FatalIfErrorf(err)
+
 	kctx.BindTo(ctx, (*context.Context)(nil))
-	kctx.Bind(db)
+	kctx.Bind(db, pol)
 
 	err = kctx.Run()
 	kctx.FatalIfErrorf(err)
@@ -0,0 +1,309 @@
+//go:generate autoquery
+package animatelocal
+
+//
+// animate-local is animate-repos for repos GitHub has no dependency graph
+// of, eg. private repos or orgs that disabled it. It walks a checked out
+// repo, parses the manifests it understands and resolves each package to
+// its GitHub repository through the package's registry:
+//	- go.mod: github.com module paths directly, others through their
+//	  go-import and go-source meta tags;
+//	- package.json, package-lock.json: the npm registry;
+//	- requirements.txt, poetry.lock: PyPI;
+//	- Cargo.toml: crates.io;
+//	- Gemfile.lock: rubygems.org.
+// The results are stored like animate-repos does: the repo in repos, each
+// manifest in manifests and each package in dependencies. Packages which
+// couldn't be resolved are stored without an owner. Sponsorable owners the
+// recipient policy allows are added to donations and donation_dependents.
+//
+// The repo is marked animated in the same transaction everything is
+// stored in, so animate-repos doesn't pick it up until dl-repos bumps its
+// last_ts. A lease animate-repos held on it is dropped.
+//
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+
+	utils "github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+	"github.com/thnxdev/utils/utils/registry"
+)
+
+type CmdAnimateLocal struct {
+	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
+	Dir                  string              `arg:"" help:"The checked out repo to animate." type:"existingdir"`
+	Entity               utils.Entity        `help:"The GitHub entity sponsoring the repo's dependencies." required:""`
+	RepoName             string              `help:"The name of the repo, the directory's name by default."`
+	Concurrency          int                 `help:"Number of packages to resolve concurrently." default:"8"`
+}
+
+// Directories which hold installed or generated dependencies rather than
+// the repo's own manifests.
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	".venv":        true,
+	"venv":         true,
+	"__pycache__":  true,
+}
+
+// manifest is a manifest file found in the repo and the packages it
+// declares. Path is relative to the repo.
+type manifest struct {
+	Filename string
+	Path     string
+	Pkgs     []pkg
+}
+
+// Validate requires at least one package to be resolved at a time.
+func (c *CmdAnimateLocal) Validate() error {
+	if c.Concurrency < 1 {
+		return errors.Errorf("concurrency must be at least 1, got %d", c.Concurrency)
+	}
+	return nil
+}
+
+func (c *CmdAnimateLocal) Run(
+	ctx context.Context,
+	db *database.DB,
+	pol *policy.Policy,
+) error {
+	logger := log.FromContext(ctx)
+	logger.Info("starting")
+
+	root, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	repoName := c.RepoName
+	if repoName == "" {
+		repoName = filepath.Base(root)
+	}
+
+	manifests, err := findManifests(ctx, root)
+	if err != nil {
+		return err
+	}
+	logger.Infof("found %d manifests in %s", len(manifests), root)
+
+	repos, err := c.resolve(ctx, manifests)
+	if err != nil {
+		return err
+	}
+
+	owners, err := c.owners(ctx, repos)
+	if err != nil {
+		return err
+	}
+
+	return db.Tx(ctx, func(q *database.Queries) error {
+		/* autoquery name: UpsertLocalRepo :exec
+
+		INSERT INTO repos (owner_name, repo_name, last_ts, animate_ts, animate_start_ts, is_local)
+		VALUES (?, ?, UNIXEPOCH(), UNIXEPOCH(), UNIXEPOCH(), TRUE)
+		ON CONFLICT (owner_name, repo_name)
+		DO UPDATE SET
+			last_ts = excluded.last_ts,
+			animate_ts = excluded.animate_ts,
+			animate_start_ts = excluded.animate_start_ts,
+			cursor_manifest = NULL,
+			cursor_dep = NULL,
+			lease_id = '',
+			lease_ts = 0,
+			inactive_reason = '';
+		*/
+		err := q.UpsertLocalRepo(ctx, database.UpsertLocalRepoParams{
+			OwnerName: string(c.Entity),
+			RepoName:  repoName,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to upsert repo")
+		}
+
+		for _, m := range manifests {
+			manifestID, err := q.UpsertManifest(ctx, database.UpsertManifestParams{
+				OwnerName: string(c.Entity),
+				RepoName:  repoName,
+				Filename:  m.Filename,
+				BlobPath:  "/" + string(c.Entity) + "/" + repoName + "/blob/HEAD/" + m.Path,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to upsert manifest")
+			}
+
+			for _, p := range m.Pkgs {
+				r := repos[p]
+				o := owners[r.Owner]
+				err = q.UpsertDependency(ctx, database.UpsertDependencyParams{
+					ManifestID:    manifestID,
+					PackageName:   p.Name,
+					DepOwnerName:  r.Owner,
+					DepRepoName:   r.Name,
+					IsSponsorable: o.Sponsorable,
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to upsert dependency")
+				}
+
+				if !o.Sponsorable {
+					continue
+				}
+				if ok, reason := pol.Check(r.Owner, o.Typename); !ok {
+					logger.Infof("skipping %s: %s", r.Owner, reason)
+					continue
+				}
+
+				err = q.InsertDonation(ctx, database.InsertDonationParams{
+					SponsorID:   string(c.Entity),
+					RecipientID: r.Owner,
+					LastTs:      time.Now().Unix(),
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to insert donation")
+				}
+				err = q.InsertDonationDependent(ctx, database.InsertDonationDependentParams{
+					SponsorID:   string(c.Entity),
+					RecipientID: r.Owner,
+					RepoName:    repoName,
+					Manifest:    m.Filename,
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to insert donation dependent")
+				}
+				logger.Debugf("fundable %s", r.Owner)
+			}
+		}
+		return nil
+	})
+}
+
+// findManifests walks the repo at root for the manifests there is a parser
+// for. Manifests which fail to parse are logged and skipped.
+func findManifests(ctx context.Context, root string) ([]manifest, error) {
+	var manifests []manifest
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		parse, ok := parsers[d.Name()]
+		if !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		pkgs, err := parse(path, b)
+		if err != nil {
+			log.FromContext(ctx).WithError(err).Warnf("skipping %s", rel)
+			return nil
+		}
+		log.FromContext(ctx).Debugf("processing manifest %s(%d)", rel, len(pkgs))
+		manifests = append(manifests, manifest{Filename: d.Name(), Path: rel, Pkgs: pkgs})
+		return nil
+	})
+	return manifests, errors.Wrap(err, "failed to walk repo")
+}
+
+// resolve returns the GitHub repository of every package in the manifests
+// which has one. Packages which fail to resolve are logged and left out.
+func (c *CmdAnimateLocal) resolve(ctx context.Context, manifests []manifest) (map[pkg]registry.Repo, error) {
+	resolver := registry.NewResolver(&http.Client{Timeout: time.Minute})
+
+	var pkgs []pkg
+	seen := map[pkg]bool{}
+	for _, m := range manifests {
+		for _, p := range m.Pkgs {
+			if !seen[p] {
+				seen[p] = true
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+
+	names := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		names[i] = p.Name
+	}
+	repos, err := registry.ResolveAll(ctx, names, c.Concurrency, func(ctx context.Context, i int) (registry.Repo, bool, error) {
+		return resolver.Resolve(ctx, pkgs[i].Ecosystem, pkgs[i].Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[pkg]registry.Repo{}
+	for i, p := range pkgs {
+		if repos[i].Owner != "" {
+			out[p] = repos[i]
+		}
+	}
+	log.FromContext(ctx).Infof("resolved %d of %d packages to GitHub repos", len(out), len(pkgs))
+	return out, nil
+}
+
+// owners looks up whether the owner of each repo has a GitHub Sponsors
+// listing. Owners which fail to be looked up are logged and left out.
+func (c *CmdAnimateLocal) owners(ctx context.Context, repos map[pkg]registry.Repo) (map[string]registry.Owner, error) {
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	)
+	client := githubv4.NewClient(
+		oauth2.NewClient(
+			hctx,
+			oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: string(c.GhClassicAccessToken),
+			}),
+		),
+	)
+
+	owners := map[string]registry.Owner{}
+	failed := map[string]bool{}
+	for _, r := range repos {
+		if _, ok := owners[r.Owner]; ok || failed[r.Owner] {
+			continue
+		}
+		o, err := registry.GetOwner(ctx, client, r.Owner)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.FromContext(ctx).WithError(err).Warnf("failed to look up %s", r.Owner)
+			failed[r.Owner] = true
+			continue
+		}
+		owners[r.Owner] = o
+	}
+	log.FromContext(ctx).Infof("looked up %d owners, %d failed", len(owners), len(failed))
+	return owners, nil
+}
@@ -0,0 +1,206 @@
+package animatelocal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/errors"
+	"golang.org/x/mod/modfile"
+
+	"github.com/thnxdev/utils/utils/registry"
+)
+
+// pkg is a dependency declared in a manifest.
+type pkg struct {
+	Ecosystem string
+	Name      string
+}
+
+// parsers are the manifests animate-local understands, by filename.
+var parsers = map[string]func(path string, b []byte) ([]pkg, error){
+	"go.mod":            parseGoMod,
+	"package.json":      parsePackageJSON,
+	"package-lock.json": parsePackageLock,
+	"requirements.txt":  parseRequirements,
+	"poetry.lock":       parsePoetryLock,
+	"Cargo.toml":        parseCargoToml,
+	"Gemfile.lock":      parseGemfileLock,
+}
+
+func parseGoMod(path string, b []byte) ([]pkg, error) {
+	f, err := modfile.ParseLax(path, b, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pkgs := make([]pkg, 0, len(f.Require))
+	for _, r := range f.Require {
+		pkgs = append(pkgs, pkg{registry.Golang, r.Mod.Path})
+	}
+	return pkgs, nil
+}
+
+func parsePackageJSON(_ string, b []byte) ([]pkg, error) {
+	var v struct {
+		Dependencies         map[string]string
+		DevDependencies      map[string]string
+		OptionalDependencies map[string]string
+	}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := map[string]bool{}
+	for _, deps := range []map[string]string{v.Dependencies, v.DevDependencies, v.OptionalDependencies} {
+		for name := range deps {
+			names[name] = true
+		}
+	}
+	return pkgsOf(registry.Npm, names), nil
+}
+
+// parsePackageLock reads both the packages (lockfile v2 and v3) and the
+// dependencies (v1) of a package-lock.json.
+func parsePackageLock(_ string, b []byte) ([]pkg, error) {
+	var v struct {
+		Packages     map[string]json.RawMessage
+		Dependencies map[string]json.RawMessage
+	}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := map[string]bool{}
+	for path := range v.Packages {
+		i := strings.LastIndex(path, "node_modules/")
+		if i == -1 {
+			// The root package.
+			continue
+		}
+		names[path[i+len("node_modules/"):]] = true
+	}
+	for name := range v.Dependencies {
+		names[name] = true
+	}
+	return pkgsOf(registry.Npm, names), nil
+}
+
+var requirementRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+func parseRequirements(_ string, b []byte) ([]pkg, error) {
+	names := map[string]bool{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		line = strings.TrimSpace(line)
+		// Options such as -r other.txt or --index-url.
+		if strings.HasPrefix(line, "-") {
+			continue
+		}
+		if name := requirementRe.FindString(line); name != "" {
+			names[name] = true
+		}
+	}
+	return pkgsOf(registry.Pypi, names), errors.WithStack(s.Err())
+}
+
+func parsePoetryLock(_ string, b []byte) ([]pkg, error) {
+	names := map[string]bool{}
+	inPackage := false
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			inPackage = line == "[[package]]"
+			continue
+		}
+		if key, value, ok := tomlKeyValue(line); inPackage && ok && key == "name" {
+			names[unquote(value)] = true
+		}
+	}
+	return pkgsOf(registry.Pypi, names), errors.WithStack(s.Err())
+}
+
+// parseCargoToml reads the dependencies, dev-dependencies and
+// build-dependencies tables, including target specific ones, in both
+// their inline and [dependencies.name] forms. Renamed dependencies are
+// resolved by their package key.
+func parseCargoToml(_ string, b []byte) ([]pkg, error) {
+	names := map[string]bool{}
+	inDeps := false
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			table := strings.Trim(line, "[] ")
+			inDeps = false
+			for _, t := range []string{"dependencies", "dev-dependencies", "build-dependencies"} {
+				switch {
+				case table == t || strings.HasSuffix(table, "."+t):
+					inDeps = true
+				case strings.HasPrefix(table, t+"."):
+					names[unquote(strings.TrimPrefix(table, t+"."))] = true
+				}
+			}
+			continue
+		}
+		key, value, ok := tomlKeyValue(line)
+		if !inDeps || !ok {
+			continue
+		}
+		name := unquote(strings.TrimSuffix(key, ".workspace"))
+		if m := cargoPackageRe.FindStringSubmatch(value); m != nil {
+			name = m[1]
+		}
+		names[name] = true
+	}
+	return pkgsOf(registry.Cargo, names), errors.WithStack(s.Err())
+}
+
+var cargoPackageRe = regexp.MustCompile(`\bpackage\s*=\s*"([^"]+)"`)
+
+// parseGemfileLock reads the top level specs of the GEM sections.
+func parseGemfileLock(_ string, b []byte) ([]pkg, error) {
+	names := map[string]bool{}
+	inGem := false
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := s.Text()
+		if line != "" && !strings.HasPrefix(line, " ") {
+			inGem = line == "GEM"
+			continue
+		}
+		if !inGem || !strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "     ") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		names[name] = true
+	}
+	return pkgsOf(registry.Gem, names), errors.WithStack(s.Err())
+}
+
+func tomlKeyValue(line string) (key, value string, ok bool) {
+	if strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
+
+func pkgsOf(ecosystem string, names map[string]bool) []pkg {
+	pkgs := make([]pkg, 0, len(names))
+	for name := range names {
+		if name != "" {
+			pkgs = append(pkgs, pkg{ecosystem, name})
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
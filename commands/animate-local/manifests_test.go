@@ -0,0 +1,220 @@
+package animatelocal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thnxdev/utils/utils/registry"
+)
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		text     string
+		names    []string
+	}{
+		{
+			name:     "go.mod",
+			filename: "go.mod",
+			text: `module example.com/app
+
+go 1.21
+
+require github.com/alecthomas/kong v0.8.0
+
+require (
+	golang.org/x/mod v0.12.0
+	github.com/google/uuid v1.3.0 // indirect
+)
+
+replace github.com/alecthomas/kong => ../kong
+`,
+			names: []string{"github.com/alecthomas/kong", "golang.org/x/mod", "github.com/google/uuid"},
+		},
+		{
+			name:     "package.json",
+			filename: "package.json",
+			text: `{
+	"name": "app",
+	"dependencies": {"react": "^18.0.0", "@scope/lib": "1.0.0"},
+	"devDependencies": {"typescript": "^5.0.0", "react": "^18.0.0"},
+	"optionalDependencies": {"fsevents": "*"},
+	"peerDependencies": {"ignored": "*"}
+}`,
+			names: []string{"@scope/lib", "fsevents", "react", "typescript"},
+		},
+		{
+			name:     "package-lock.json v1",
+			filename: "package-lock.json",
+			text: `{
+	"lockfileVersion": 1,
+	"dependencies": {
+		"react": {"version": "18.0.0"},
+		"@scope/lib": {"version": "1.0.0"}
+	}
+}`,
+			names: []string{"@scope/lib", "react"},
+		},
+		{
+			name:     "package-lock.json v2",
+			filename: "package-lock.json",
+			text: `{
+	"lockfileVersion": 2,
+	"packages": {
+		"": {"name": "app"},
+		"node_modules/react": {"version": "18.0.0"},
+		"node_modules/@scope/lib": {"version": "1.0.0"}
+	},
+	"dependencies": {
+		"react": {"version": "18.0.0"},
+		"@scope/lib": {"version": "1.0.0"}
+	}
+}`,
+			names: []string{"@scope/lib", "react"},
+		},
+		{
+			name:     "package-lock.json v3",
+			filename: "package-lock.json",
+			text: `{
+	"lockfileVersion": 3,
+	"packages": {
+		"": {"name": "app"},
+		"node_modules/react": {"version": "18.0.0"},
+		"node_modules/a/node_modules/nested": {"version": "1.0.0"},
+		"packages/workspace": {"version": "1.0.0"}
+	}
+}`,
+			names: []string{"nested", "react"},
+		},
+		{
+			name:     "requirements.txt",
+			filename: "requirements.txt",
+			text: `# the app
+-r base.txt
+--index-url https://example.com/simple
+requests>=2.0
+Django==4.2  # web
+zope.interface
+python-dateutil ; python_version >= "3.8"
+
+numpy[extra]~=1.24
+`,
+			names: []string{"Django", "numpy", "python-dateutil", "requests", "zope.interface"},
+		},
+		{
+			name:     "poetry.lock",
+			filename: "poetry.lock",
+			text: `[[package]]
+name = "requests"
+version = "2.31.0"
+
+[package.dependencies]
+name = "not-a-package"
+
+[[package]]
+name = 'urllib3'
+version = "2.0.0"
+
+[metadata]
+name = "not-a-package-either"
+`,
+			names: []string{"requests", "urllib3"},
+		},
+		{
+			name:     "Cargo.toml",
+			filename: "Cargo.toml",
+			text: `[package]
+name = "app"
+version = "0.1.0"
+
+[dependencies]
+serde = { version = "1", features = ["derive"] }
+tokio = "1"
+# commented = "1"
+my-log = { package = "log", version = "0.4" }
+shared.workspace = true
+
+[dev-dependencies]
+criterion = "0.5"
+
+[build-dependencies]
+cc = "1"
+
+[target.'cfg(unix)'.dependencies]
+libc = "0.2"
+
+[dependencies.regex]
+version = "1"
+
+[features]
+default = ["serde"]
+`,
+			names: []string{"cc", "criterion", "libc", "log", "regex", "serde", "shared", "tokio"},
+		},
+		{
+			name:     "Gemfile.lock",
+			filename: "Gemfile.lock",
+			text: `GIT
+  remote: https://github.com/owner/gem.git
+  specs:
+    from-git (1.0.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    rack (3.0.0)
+    rails (7.0.0)
+      rack (>= 2.2)
+      actionpack (= 7.0.0)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  rails
+`,
+			names: []string{"rack", "rails"},
+		},
+	}
+	ecosystems := map[string]string{
+		"go.mod":            registry.Golang,
+		"package.json":      registry.Npm,
+		"package-lock.json": registry.Npm,
+		"requirements.txt":  registry.Pypi,
+		"poetry.lock":       registry.Pypi,
+		"Cargo.toml":        registry.Cargo,
+		"Gemfile.lock":      registry.Gem,
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkgs, err := parsers[test.filename](test.filename, []byte(test.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, p := range pkgs {
+				if p.Ecosystem != ecosystems[test.filename] {
+					t.Errorf("%s is in ecosystem %q, want %q", p.Name, p.Ecosystem, ecosystems[test.filename])
+				}
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf("got %q, want %q", names, test.names)
+			}
+		})
+	}
+}
+
+func TestParsersInvalid(t *testing.T) {
+	for filename, text := range map[string]string{
+		"go.mod":            "require (\n\tgithub.com/owner/repo\n",
+		"package.json":      "{invalid",
+		"package-lock.json": "{invalid",
+	} {
+		_, err := parsers[filename](filename, []byte(text))
+		if err == nil {
+			t.Errorf("parsing an invalid %s succeeded", filename)
+		}
+	}
+}
@@ -1,6 +1,48 @@
 //go:generate autoquery
 package animaterepos
 
+//
+// The dependency graph of a repo is paged through one manifest at a time,
+// each manifest's dependencies 100 at a time. The position is stored in
+// repos.cursor_manifest and repos.cursor_dep after every page so an
+// interrupted animation resumes where it left off:
+//	- cursor_manifest points before the manifest being processed;
+//	- cursor_dep points into that manifest's dependencies, and is reset
+//	  whenever cursor_manifest advances;
+//	- both are cleared and animate_ts is set once the last page is done.
+// Every write is an upsert so re-processing a page after a crash is safe.
+//
+// Repos are leased to --concurrency workers through repos.lease_id and
+// repos.lease_ts. The lease is renewed after every page and released once
+// the repo is done or the run stops. Leases left behind by a killed run
+// expire after --lease-timeout so the repo is picked up again. Cursors and
+// animate_ts are only written under the worker's own lease, so a worker
+// which stalled past the timeout, eg. waiting out the rate limit, stops
+// once another one took the repo over.
+//
+// Sponsorable dependencies are only added to the donations table if the
+// recipient policy allows them and they pass the filters on manifest
+// paths, package managers and scope. Dependencies are stored regardless,
+// with the reason they were filtered out in dependencies.excluded_by.
+// The dependency graph doesn't tell dev dependencies apart, so with
+// --scope=runtime each manifest is fetched to look for them.
+//
+// With --funding each dependency repo's .github/FUNDING.yml is fetched
+// along with its page. Its platforms and handles replace the repo's rows
+// in funding_targets, and its github entries are added to the donations
+// table like a sponsorable owner, even if they aren't the repo's owner.
+//
+// Repos dl-repos marked inactive aren't animated. The oldest pending repos
+// are animated first. A repo whose animation fails keeps its lease and
+// cursors until the run ends, so the run carries on with the other repos,
+// and its error is stored in repos.animate_error. Failed repos are tried
+// after the others on the next run.
+//
+// repos.animate_start_ts records when the current animation of a repo
+// started. Dependencies with an older last_ts weren't seen by it, which
+// prune uses to find recipients the repo no longer depends on.
+//
+
 import (
 	"context"
 	"database/sql"
@@ -8,177 +50,527 @@ import (
 	"time"
 
 	"github.com/alecthomas/errors"
+	"github.com/google/uuid"
 	"github.com/shurcooL/githubv4"
 	utils "github.com/thnxdev/utils"
 	"github.com/thnxdev/utils/database"
 	"github.com/thnxdev/utils/utils/httpgh"
 	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
 	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
 )
 
 type CmdAnimateRepos struct {
 	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
+	Concurrency          int                 `help:"Number of repos to animate concurrently." default:"1"`
+	LeaseTimeout         time.Duration       `help:"How long a repo stays leased to a worker that stopped making progress." default:"10m"`
+	Funding              bool                `help:"Read each dependency repo's FUNDING.yml, record its funding targets and sponsor its github entries."`
+	IncludeManifests     []string            `help:"Only fund dependencies of manifests matching these path globs, eg. 'services/**'."`
+	ExcludeManifests     []string            `help:"Don't fund dependencies of manifests matching these path globs, eg. 'docs/**'."`
+	PackageManagers      []string            `help:"Only fund dependencies of these package managers, eg. NPM or GO."`
+	Scope                string              `help:"Fund all dependencies or only runtime ones (${enum})." enum:"all,runtime" default:"all"`
+}
+
+// options are how the dependencies of repos are animated.
+type options struct {
+	Funding bool
+	Filter  filter
+}
+
+// Validate requires at least one worker and lease timeouts of a second or
+// more, which leases are stored in.
+func (c *CmdAnimateRepos) Validate() error {
+	if c.Concurrency < 1 {
+		return errors.Errorf("concurrency must be at least 1, got %d", c.Concurrency)
+	}
+	if c.LeaseTimeout < time.Second {
+		return errors.Errorf("lease timeout must be at least 1s, got %s", c.LeaseTimeout)
+	}
+	return nil
 }
 
 func (c *CmdAnimateRepos) Run(
 	ctx context.Context,
 	db *database.DB,
+	pol *policy.Policy,
 ) error {
 	logger := log.FromContext(ctx)
 	logger.Info("starting")
 
+	transport := httpgh.NewTransport(nil)
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: transport},
+	)
+
+	client := githubv4.NewClient(
+		oauth2.NewClient(
+			hctx,
+			oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: string(c.GhClassicAccessToken),
+			}),
+		),
+	)
+
+	opts := options{
+		Funding: c.Funding,
+		Filter: filter{
+			IncludeManifests: c.IncludeManifests,
+			ExcludeManifests: c.ExcludeManifests,
+			PackageManagers:  c.PackageManagers,
+			RuntimeOnly:      c.Scope == "runtime",
+		},
+	}
+
+	leaseID := uuid.NewString()
+	defer func() {
+		/* autoquery name: ReleaseRepoLeases :exec
+
+		UPDATE repos
+		SET lease_id = '', lease_ts = 0
+		WHERE lease_id = ?;
+		*/
+		err := db.ReleaseRepoLeases(context.Background(), leaseID)
+		if err != nil {
+			logger.WithError(err).Error("failed to release repo leases")
+		}
+	}()
+
+	wg, wctx := errgroup.WithContext(ctx)
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Go(func() error {
+			return animate(wctx, db, client, pol, opts, leaseID, c.LeaseTimeout)
+		})
+	}
+	err := wg.Wait()
+
+	remaining, reset := transport.Remaining()
+	logger.Infof("github rate limit: %d remaining until %s", remaining, reset)
+
+	return err
+}
+
+// animate is a worker which leases repos pending animation and processes
+// every page of their dependency graph until no repo is left.
+func animate(
+	ctx context.Context,
+	db *database.DB,
+	client *githubv4.Client,
+	pol *policy.Policy,
+	opts options,
+	leaseID string,
+	leaseTimeout time.Duration,
+) error {
 	for {
-		/* autoquery name: GetRepos :one
+		/* autoquery name: ClaimRepo :one
 
-		SELECT owner_name, repo_name, cursor_manifest, cursor_dep
-		FROM repos
-		WHERE animate_ts < last_ts
-		LIMIT 1;
+		UPDATE repos
+		SET
+			lease_id = sqlc.arg(lease_id),
+			lease_ts = UNIXEPOCH(),
+			animate_start_ts = CASE
+				WHEN cursor_manifest IS NULL AND cursor_dep IS NULL THEN UNIXEPOCH()
+				ELSE animate_start_ts
+			END
+		WHERE rowid = (
+			SELECT rowid
+			FROM repos
+			WHERE
+				animate_ts < last_ts AND
+				inactive_reason = '' AND
+				lease_ts < UNIXEPOCH() - CAST(sqlc.arg(lease_timeout) AS INTEGER)
+			ORDER BY animate_error != '', last_ts
+			LIMIT 1
+		)
+		RETURNING owner_name, repo_name, cursor_manifest, cursor_dep;
 		*/
-		row, err := db.GetRepos(ctx)
+		row, err := db.ClaimRepo(ctx, database.ClaimRepoParams{
+			LeaseID:      leaseID,
+			LeaseTimeout: int64(leaseTimeout.Seconds()),
+		})
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return nil
 			}
-			return errors.Wrap(err, "failed to get repos")
+			return errors.Wrap(err, "failed to claim repo")
 		}
 
-		log.FromContext(ctx).Debugf(
-			"processing %s/%s",
-			row.OwnerName,
-			row.RepoName,
-		)
-
-		var mc, dc *string
+		var cur cursors
 		if row.CursorManifest.Valid {
-			mc = &row.CursorManifest.String
+			cur.Manifest = &row.CursorManifest.String
 		}
 		if row.CursorDep.Valid {
-			dc = &row.CursorDep.String
+			cur.Dep = &row.CursorDep.String
 		}
 
-		var q struct {
-			Repository struct {
-				Name                     string
-				DependencyGraphManifests struct {
-					Nodes []struct {
-						Filename    string
-						Depenencies struct {
-							Nodes []struct {
-								Repository struct {
-									Owner struct {
-										Sponsorable struct {
-											HasSponsorsListing bool
-										} `graphql:"... on Sponsorable"`
-										RepositoryOwner struct {
-											Login string
-										} `graphql:"... on RepositoryOwner"`
-									}
+		for ok := true; ok; {
+			cur, ok, err = animatePage(ctx, db, client, pol, opts, leaseID, row.OwnerName, row.RepoName, cur)
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				err = failRepo(ctx, db, leaseID, row.OwnerName, row.RepoName, err)
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+}
+
+// failRepo records why the animation of the repo failed. The repo stays
+// leased, so no worker of this run claims it again.
+func failRepo(ctx context.Context, db *database.DB, leaseID, ownerName, repoName string, failure error) error {
+	log.FromContext(ctx).WithError(failure).Errorf("failed to animate %s/%s", ownerName, repoName)
+
+	/* autoquery name: RepoSetAnimateError :exec
+
+	UPDATE repos
+	SET animate_error = ?
+	WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+	*/
+	err := db.RepoSetAnimateError(ctx, database.RepoSetAnimateErrorParams{
+		AnimateError: failure.Error(),
+		OwnerName:    ownerName,
+		RepoName:     repoName,
+		LeaseID:      leaseID,
+	})
+	return errors.Wrap(err, "failed to record animate error")
+}
+
+type pageInfo struct {
+	EndCursor   string
+	HasNextPage bool
+}
+
+// cursors is the pagination state of a repo's dependency graph.
+type cursors struct {
+	Manifest *string
+	Dep      *string
+	// Scopes of the manifest being paginated, read on its first page with
+	// --scope=runtime and kept for its next dependency pages.
+	Scopes *manifestScopes
+}
+
+// manifestScopes is what devPackages found in the manifest at BlobPath.
+type manifestScopes struct {
+	BlobPath string
+	Dev      map[string]bool
+	AllDev   bool
+	Known    bool
+}
+
+// next returns the cursors following a page with the given manifest and
+// dependency page info. ok is false once the last dependency page of the
+// last manifest has been processed.
+func (c cursors) next(manifests, deps pageInfo) (n cursors, ok bool) {
+	if deps.HasNextPage {
+		return cursors{Manifest: c.Manifest, Dep: &deps.EndCursor, Scopes: c.Scopes}, true
+	}
+	if manifests.HasNextPage {
+		return cursors{Manifest: &manifests.EndCursor}, true
+	}
+	return cursors{}, false
+}
+
+// animatePage fetches and stores the page of the repo's dependency graph
+// at cur, then stores and returns the cursors of the next page. ok is false
+// once the repo is done, or once the repo's lease expired and was taken
+// over by another worker, which then carries on from the stored cursors.
+func animatePage(
+	ctx context.Context,
+	db *database.DB,
+	client *githubv4.Client,
+	pol *policy.Policy,
+	opts options,
+	leaseID string,
+	ownerName, repoName string,
+	cur cursors,
+) (next cursors, ok bool, err error) {
+	log.FromContext(ctx).Debugf(
+		"processing %s/%s",
+		ownerName,
+		repoName,
+	)
+
+	var q struct {
+		Repository struct {
+			Name                     string
+			DependencyGraphManifests struct {
+				Nodes []struct {
+					Filename    string
+					BlobPath    string
+					Depenencies struct {
+						Nodes []struct {
+							PackageName    string
+							PackageManager string
+							Requirements   string
+							Repository     struct {
+								Name  string
+								Owner struct {
+									Typename    string `graphql:"__typename"`
+									Sponsorable struct {
+										HasSponsorsListing bool
+									} `graphql:"... on Sponsorable"`
+									RepositoryOwner struct {
+										Login string
+									} `graphql:"... on RepositoryOwner"`
 								}
+								Funding struct {
+									Blob struct {
+										Text string
+									} `graphql:"... on Blob"`
+								} `graphql:"funding: object(expression: \"HEAD:.github/FUNDING.yml\") @include(if: $funding)"`
 							}
-							PageInfo struct {
-								EndCursor   string
-								HasNextPage bool
-							}
-						} `graphql:"dependencies(first: 100, after: $depCursor)"`
-					}
-					PageInfo struct {
-						EndCursor   string
-						HasNextPage bool
-					}
-				} `graphql:"dependencyGraphManifests(first: 1, after: $manifestCursor)"`
-			} `graphql:"repository(owner: $owner, name: $name)"`
-		}
+						}
+						PageInfo pageInfo
+					} `graphql:"dependencies(first: 100, after: $depCursor)"`
+				}
+				PageInfo pageInfo
+			} `graphql:"dependencyGraphManifests(first: 1, after: $manifestCursor)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
 
-		var vars map[string]any = map[string]any{
-			"owner":          githubv4.String(row.OwnerName),
-			"name":           githubv4.String(row.RepoName),
-			"manifestCursor": (*githubv4.String)(mc),
-			"depCursor":      (*githubv4.String)(dc),
-		}
+	var vars map[string]any = map[string]any{
+		"owner":          githubv4.String(ownerName),
+		"name":           githubv4.String(repoName),
+		"manifestCursor": (*githubv4.String)(cur.Manifest),
+		"depCursor":      (*githubv4.String)(cur.Dep),
+		"funding":        githubv4.Boolean(opts.Funding),
+	}
 
-		hctx := context.WithValue(
-			ctx,
-			oauth2.HTTPClient,
-			&http.Client{Transport: httpgh.NewTransport(nil)},
-		)
+	err = client.Query(ctx, &q, vars)
+	if err != nil {
+		return next, false, errors.Wrap(err, "failed to query repos")
+	}
 
-		client := githubv4.NewClient(
-			oauth2.NewClient(
-				hctx,
-				oauth2.StaticTokenSource(&oauth2.Token{
-					AccessToken: string(c.GhClassicAccessToken),
-				}),
-			),
-		)
+	// Only one manifest is requested per page.
+	var deps pageInfo
+	// Many packages can share a repo, eg. a monorepo, so its FUNDING.yml
+	// is only recorded once per page.
+	funding := map[string][]fundingTarget{}
+	for _, m := range q.Repository.DependencyGraphManifests.Nodes {
+		log.FromContext(ctx).Debugf("processing manifest %s(%d)", m.Filename, len(m.Depenencies.Nodes))
+
+		/* autoquery name: UpsertManifest :one
 
-		err = client.Query(hctx, &q, vars)
+		INSERT INTO manifests (owner_name, repo_name, filename, blob_path, last_ts)
+		VALUES (?, ?, ?, ?, UNIXEPOCH())
+		ON CONFLICT (owner_name, repo_name, blob_path)
+		DO UPDATE SET last_ts = excluded.last_ts
+		RETURNING id;
+		*/
+		manifestID, err := db.UpsertManifest(ctx, database.UpsertManifestParams{
+			OwnerName: ownerName,
+			RepoName:  repoName,
+			Filename:  m.Filename,
+			BlobPath:  m.BlobPath,
+		})
 		if err != nil {
-			return errors.Wrap(err, "failed to query repos")
+			return next, false, errors.Wrap(err, "failed to upsert manifest")
 		}
 
-		var manifetsCursor, depCursor *string
-		for _, m := range q.Repository.DependencyGraphManifests.Nodes {
-			log.FromContext(ctx).Debugf("processing manifest %s(%d)", m.Filename, len(m.Depenencies.Nodes))
-			for _, d := range m.Depenencies.Nodes {
-				o := d.Repository.Owner
-				if o.Sponsorable.HasSponsorsListing {
-					_ = db.InsertDonation(ctx, database.InsertDonationParams{
-						SponsorID:   row.OwnerName,
-						RecipientID: o.RepositoryOwner.Login,
-						LastTs:      time.Now().Unix(),
-					})
-					log.FromContext(ctx).Debugf("fundable %s", o.RepositoryOwner.Login)
+		var dev map[string]bool
+		allDev, knownScope := false, false
+		if opts.Filter.RuntimeOnly {
+			if cur.Scopes == nil || cur.Scopes.BlobPath != m.BlobPath {
+				text, err := getManifestText(ctx, client, ownerName, repoName, m.Filename)
+				if err != nil {
+					return next, false, err
 				}
+				sc := manifestScopes{BlobPath: m.BlobPath}
+				sc.Dev, sc.AllDev, sc.Known = devPackages(m.Filename, text)
+				cur.Scopes = &sc
 			}
-			if m.Depenencies.PageInfo.HasNextPage {
-				depCursor = &m.Depenencies.PageInfo.EndCursor
-			}
+			dev, allDev, knownScope = cur.Scopes.Dev, cur.Scopes.AllDev, cur.Scopes.Known
 		}
 
-		if q.Repository.DependencyGraphManifests.PageInfo.HasNextPage {
-			manifetsCursor = &q.Repository.DependencyGraphManifests.PageInfo.EndCursor
-		}
+		for _, d := range m.Depenencies.Nodes {
+			o := d.Repository.Owner
 
-		if depCursor != nil {
-			dc := sql.NullString{String: *depCursor, Valid: true}
+			scope := ""
+			switch {
+			case !knownScope:
+			case allDev || dev[normalizePackage(d.PackageName)]:
+				scope = scopeDev
+			default:
+				scope = scopeRuntime
+			}
+			excludedBy := opts.Filter.excludedBy(m.Filename, d.PackageManager, scope)
 
-			/* autoquery name: RepoUpdateCursorDep :exec
+			/* autoquery name: UpsertDependency :exec
 
-			UPDATE repos
-			SET cursor_dep = ?
-			WHERE owner_name = ? AND repo_name = ?;
+			INSERT INTO dependencies (
+				manifest_id, package_name, dep_owner_name, dep_repo_name, is_sponsorable,
+				package_manager, requirements, scope, excluded_by, last_ts, excluded_ts
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, UNIXEPOCH(), UNIXEPOCH())
+			ON CONFLICT (manifest_id, package_name, dep_owner_name, dep_repo_name)
+			DO UPDATE SET
+				is_sponsorable = excluded.is_sponsorable,
+				package_manager = excluded.package_manager,
+				requirements = excluded.requirements,
+				scope = excluded.scope,
+				excluded_by = excluded.excluded_by,
+				last_ts = excluded.last_ts,
+				excluded_ts = CASE
+					WHEN dependencies.excluded_by = '' THEN excluded.excluded_ts
+					ELSE dependencies.excluded_ts
+				END;
 			*/
-			_ = db.RepoUpdateCursorDep(ctx, database.RepoUpdateCursorDepParams{
-				OwnerName: row.OwnerName,
-				RepoName:  row.RepoName,
-				CursorDep: dc,
+			err = db.UpsertDependency(ctx, database.UpsertDependencyParams{
+				ManifestID:     manifestID,
+				PackageName:    d.PackageName,
+				DepOwnerName:   o.RepositoryOwner.Login,
+				DepRepoName:    d.Repository.Name,
+				IsSponsorable:  o.Sponsorable.HasSponsorsListing,
+				PackageManager: d.PackageManager,
+				Requirements:   d.Requirements,
+				Scope:          scope,
+				ExcludedBy:     excludedBy,
 			})
-		} else if manifetsCursor != nil {
-			mc := sql.NullString{String: *manifetsCursor, Valid: true}
+			if err != nil {
+				return next, false, errors.Wrap(err, "failed to upsert dependency")
+			}
+			if excludedBy != "" {
+				log.FromContext(ctx).Debugf("excluding %s: %s", d.PackageName, excludedBy)
+				continue
+			}
 
-			/* autoquery name: RepoUpdateCursorManifest :exec
+			// The account type of recipients from FUNDING.yml isn't known.
+			recipients := map[string]string{}
+			if o.Sponsorable.HasSponsorsListing {
+				recipients[o.RepositoryOwner.Login] = o.Typename
+			}
 
-			UPDATE repos
-			SET cursor_manifest = ?
-			WHERE owner_name = ? AND repo_name = ?;
-			*/
-			_ = db.RepoUpdateCursorManifest(ctx, database.RepoUpdateCursorManifestParams{
-				OwnerName:      row.OwnerName,
-				RepoName:       row.RepoName,
-				CursorManifest: mc,
-			})
-		} else {
-			/* autoquery name: RepoUpdateAnimateTs :exec
+			if opts.Funding && o.RepositoryOwner.Login != "" {
+				depRepo := o.RepositoryOwner.Login + "/" + d.Repository.Name
+				targets, ok := funding[depRepo]
+				if !ok {
+					targets, err = recordFunding(ctx, db, o.RepositoryOwner.Login, d.Repository.Name, d.Repository.Funding.Blob.Text)
+					if err != nil {
+						return next, false, err
+					}
+					funding[depRepo] = targets
+				}
+				for _, t := range targets {
+					if _, ok := recipients[t.Handle]; t.Platform == "github" && !ok {
+						recipients[t.Handle] = ""
+					}
+				}
+			}
 
-			UPDATE repos
-			SET animate_ts = UNIXEPOCH()
-			WHERE owner_name = ? AND repo_name = ?;
-			*/
-			_ = db.RepoUpdateAnimateTs(ctx, database.RepoUpdateAnimateTsParams{
-				OwnerName: row.OwnerName,
-				RepoName:  row.RepoName,
-			})
+			for login, typ := range recipients {
+				if ok, reason := pol.Check(login, typ); !ok {
+					log.FromContext(ctx).Infof("skipping %s: %s", login, reason)
+					continue
+				}
+
+				_ = db.InsertDonation(ctx, database.InsertDonationParams{
+					SponsorID:   ownerName,
+					RecipientID: login,
+					LastTs:      time.Now().Unix(),
+				})
+
+				/* autoquery name: InsertDonationDependent :exec
+
+				INSERT INTO donation_dependents (sponsor_id, recipient_id, repo_name, manifest, last_ts)
+				VALUES (?, ?, ?, ?, UNIXEPOCH())
+				ON CONFLICT (sponsor_id, recipient_id, repo_name, manifest)
+				DO UPDATE SET last_ts = excluded.last_ts;
+				*/
+				_ = db.InsertDonationDependent(ctx, database.InsertDonationDependentParams{
+					SponsorID:   ownerName,
+					RecipientID: login,
+					RepoName:    repoName,
+					Manifest:    m.Filename,
+				})
+				log.FromContext(ctx).Debugf("fundable %s", login)
+			}
+		}
+		deps = m.Depenencies.PageInfo
+	}
+
+	next, ok = cur.next(q.Repository.DependencyGraphManifests.PageInfo, deps)
+	if !ok {
+		/* autoquery name: RepoUpdateAnimateTs :execrows
+
+		UPDATE repos
+		SET
+			animate_ts = UNIXEPOCH(),
+			cursor_manifest = NULL,
+			cursor_dep = NULL,
+			lease_id = '',
+			lease_ts = 0,
+			animate_error = ''
+		WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+		*/
+		updated, err := db.RepoUpdateAnimateTs(ctx, database.RepoUpdateAnimateTsParams{
+			OwnerName: ownerName,
+			RepoName:  repoName,
+			LeaseID:   leaseID,
+		})
+		if err != nil {
+			return next, false, errors.Wrap(err, "failed to update animate ts")
 		}
+		if updated == 0 {
+			log.FromContext(ctx).Warnf("lost the lease of %s/%s", ownerName, repoName)
+		}
+		return next, false, nil
+	}
+
+	/* autoquery name: RepoUpdateCursors :execrows
+
+	UPDATE repos
+	SET cursor_manifest = ?, cursor_dep = ?, lease_ts = UNIXEPOCH()
+	WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+	*/
+	updated, err := db.RepoUpdateCursors(ctx, database.RepoUpdateCursorsParams{
+		CursorManifest: nullString(next.Manifest),
+		CursorDep:      nullString(next.Dep),
+		OwnerName:      ownerName,
+		RepoName:       repoName,
+		LeaseID:        leaseID,
+	})
+	if err != nil {
+		return next, false, errors.Wrap(err, "failed to update cursors")
+	}
+	if updated == 0 {
+		log.FromContext(ctx).Warnf("lost the lease of %s/%s", ownerName, repoName)
+		return next, false, nil
+	}
+	return next, true, nil
+}
+
+// getManifestText returns the contents of the manifest at filename in the
+// repo's default branch, or "" if it can't be read as text.
+func getManifestText(ctx context.Context, client *githubv4.Client, ownerName, repoName, filename string) (string, error) {
+	var q struct {
+		Repository struct {
+			Object struct {
+				Blob struct {
+					Text string
+				} `graphql:"... on Blob"`
+			} `graphql:"object(expression: $expression)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	err := client.Query(ctx, &q, map[string]any{
+		"owner":      githubv4.String(ownerName),
+		"name":       githubv4.String(repoName),
+		"expression": githubv4.String("HEAD:" + filename),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get manifest %s", filename)
+	}
+	return q.Repository.Object.Blob.Text, nil
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
 	}
+	return database.String(*s)
 }
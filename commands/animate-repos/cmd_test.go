@@ -0,0 +1,692 @@
+package animaterepos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/policy"
+	"golang.org/x/sync/errgroup"
+)
+
+type dep struct {
+	Owner       string
+	Sponsorable bool
+}
+
+type fakeManifest struct {
+	Filename string
+	// Dependency pages, each page is served for the cursor of the previous one.
+	Pages [][]dep
+}
+
+// fakeGraph is a fake GitHub GraphQL server serving a single repo's
+// dependency graph one manifest and one dependency page at a time, with
+// cursors of the form "m<manifest>" and "m<manifest>d<page>".
+type fakeGraph struct {
+	t         *testing.T
+	manifests []fakeManifest
+	// FUNDING.yml of the dependency repos, by owner.
+	funding map[string]string
+	// Contents of the manifests, by filename.
+	texts map[string]string
+
+	mu           sync.Mutex
+	requests     [][2]string
+	textRequests []string
+	failAt       int
+}
+
+func (f *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Variables struct {
+			ManifestCursor *string `json:"manifestCursor"`
+			DepCursor      *string `json:"depCursor"`
+			Expression     string  `json:"expression"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.t.Errorf("invalid request: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if filename, ok := strings.CutPrefix(body.Variables.Expression, "HEAD:"); ok {
+		f.mu.Lock()
+		f.textRequests = append(f.textRequests, filename)
+		f.mu.Unlock()
+		writeJSON(w, map[string]any{"data": map[string]any{"repository": map[string]any{
+			"object": map[string]any{"text": f.texts[filename]},
+		}}})
+		return
+	}
+	mc, dc := deref(body.Variables.ManifestCursor), deref(body.Variables.DepCursor)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, [2]string{mc, dc})
+	n := len(f.requests)
+	f.mu.Unlock()
+
+	if n == f.failAt {
+		http.Error(w, "boom", http.StatusBadGateway)
+		return
+	}
+
+	mi := 0
+	if mc != "" {
+		mi = indexOf(f.t, cursorsOf(len(f.manifests), func(i int) string { return manifestCursor(i) }), mc) + 1
+	}
+	if mi >= len(f.manifests) {
+		writeJSON(w, map[string]any{"data": map[string]any{"repository": map[string]any{
+			"name": "app",
+			"dependencyGraphManifests": map[string]any{
+				"nodes":    []any{},
+				"pageInfo": map[string]any{"endCursor": "", "hasNextPage": false},
+			},
+		}}})
+		return
+	}
+	m := f.manifests[mi]
+
+	pi := 0
+	if dc != "" {
+		pi = indexOf(f.t, cursorsOf(len(m.Pages), func(i int) string { return depCursor(mi, i) }), dc) + 1
+	}
+
+	nodes := []any{}
+	for _, d := range m.Pages[pi] {
+		repo := map[string]any{
+			"name": "pkg",
+			"owner": map[string]any{
+				"hasSponsorsListing": d.Sponsorable,
+				"login":              d.Owner,
+			},
+		}
+		if text, ok := f.funding[d.Owner]; ok {
+			repo["funding"] = map[string]any{"text": text}
+		}
+		pm := "GO"
+		if m.Filename == "package.json" {
+			pm = "NPM"
+		}
+		nodes = append(nodes, map[string]any{
+			"packageName":    d.Owner + "/pkg",
+			"packageManager": pm,
+			"repository":     repo,
+		})
+	}
+
+	writeJSON(w, map[string]any{"data": map[string]any{"repository": map[string]any{
+		"name": "app",
+		"dependencyGraphManifests": map[string]any{
+			"nodes": []any{map[string]any{
+				"filename": m.Filename,
+				"blobPath": "/acme/app/blob/main/" + m.Filename,
+				"dependencies": map[string]any{
+					"nodes": nodes,
+					"pageInfo": map[string]any{
+						"endCursor":   depCursor(mi, pi),
+						"hasNextPage": pi < len(m.Pages)-1,
+					},
+				},
+			}},
+			"pageInfo": map[string]any{
+				"endCursor":   manifestCursor(mi),
+				"hasNextPage": mi < len(f.manifests)-1,
+			},
+		},
+	}}})
+}
+
+func manifestCursor(i int) string { return "m" + string(rune('0'+i)) }
+func depCursor(m, i int) string   { return manifestCursor(m) + "d" + string(rune('0'+i)) }
+
+func cursorsOf(n int, f func(int) string) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = f(i)
+	}
+	return out
+}
+
+func indexOf(t *testing.T, cursors []string, c string) int {
+	for i, v := range cursors {
+		if v == c {
+			return i
+		}
+	}
+	t.Errorf("unexpected cursor %q", c)
+	return len(cursors)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func setup(t *testing.T, f *fakeGraph) (context.Context, *database.DB, *sql.DB, *githubv4.Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	db, conn := database.OpenTest(t)
+	err := db.ReposUpsert(ctx, database.ReposUpsertParams{OwnerName: "acme", RepoName: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx, db, conn, githubv4.NewEnterpriseClient(srv.URL, srv.Client())
+}
+
+func graph(t *testing.T) *fakeGraph {
+	return &fakeGraph{
+		t: t,
+		manifests: []fakeManifest{
+			{Filename: "go.mod", Pages: [][]dep{
+				{{"alice", true}, {"bob", false}},
+				{{"carol", true}},
+			}},
+			{Filename: "package.json", Pages: [][]dep{
+				{{"dave", true}},
+			}},
+		},
+	}
+}
+
+func dependencies(t *testing.T, conn *sql.DB) []string {
+	t.Helper()
+	rows, err := conn.Query(`
+		SELECT m.filename || ':' || d.dep_owner_name
+		FROM dependencies d JOIN manifests m ON m.id = d.manifest_id
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func repoState(t *testing.T, conn *sql.DB) (manifest, dep sql.NullString, animateTs int64) {
+	t.Helper()
+	err := conn.QueryRow(`SELECT cursor_manifest, cursor_dep, animate_ts FROM repos`).Scan(&manifest, &dep, &animateTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestAnimatePaginatesManifestsAndDependencies(t *testing.T) {
+	f := graph(t)
+	ctx, db, conn, client := setup(t, f)
+
+	err := animate(ctx, db, client, &policy.Policy{}, options{}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRequests := [][2]string{
+		{"", ""},
+		{"", "m0d0"},
+		// The dependency cursor must be reset when the manifest advances.
+		{"m0", ""},
+	}
+	if !reflect.DeepEqual(f.requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", f.requests, wantRequests)
+	}
+
+	wantDeps := []string{"go.mod:alice", "go.mod:bob", "go.mod:carol", "package.json:dave"}
+	if got := dependencies(t, conn); !reflect.DeepEqual(got, wantDeps) {
+		t.Errorf("dependencies = %v, want %v", got, wantDeps)
+	}
+
+	mc, dc, animateTs := repoState(t, conn)
+	if mc.Valid || dc.Valid {
+		t.Errorf("cursors = %v, %v, want cleared", mc, dc)
+	}
+	if animateTs == 0 {
+		t.Error("animate_ts not set")
+	}
+
+	var donations int
+	err = conn.QueryRow(`SELECT COUNT(*) FROM donations`).Scan(&donations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if donations != 3 {
+		t.Errorf("donations = %d, want 3", donations)
+	}
+}
+
+func animateError(t *testing.T, conn *sql.DB) string {
+	t.Helper()
+	var failure string
+	err := conn.QueryRow(`SELECT animate_error FROM repos`).Scan(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return failure
+}
+
+func TestAnimateResumesAfterFailure(t *testing.T) {
+	f := graph(t)
+	f.failAt = 2
+	ctx, db, conn, client := setup(t, f)
+
+	// The failure is recorded and the run carries on with the other repos.
+	err := animate(ctx, db, client, &policy.Policy{}, options{}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure := animateError(t, conn); failure == "" {
+		t.Error("animate_error not set")
+	}
+
+	mc, dc, animateTs := repoState(t, conn)
+	if mc.Valid || dc.String != "m0d0" || animateTs != 0 {
+		t.Fatalf("state = %v, %v, %d, want resumable from m0d0", mc, dc, animateTs)
+	}
+
+	// The repo stays leased until the run releases it.
+	err = animate(ctx, db, client, &policy.Policy{}, options{}, "other", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.requests) != 2 {
+		t.Fatalf("leased repo was claimed by another worker")
+	}
+	err = db.ReleaseRepoLeases(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = animate(ctx, db, client, &policy.Policy{}, options{}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRequests := [][2]string{
+		{"", ""},
+		{"", "m0d0"},
+		{"", "m0d0"},
+		{"m0", ""},
+	}
+	if !reflect.DeepEqual(f.requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", f.requests, wantRequests)
+	}
+
+	wantDeps := []string{"go.mod:alice", "go.mod:bob", "go.mod:carol", "package.json:dave"}
+	if got := dependencies(t, conn); !reflect.DeepEqual(got, wantDeps) {
+		t.Errorf("dependencies = %v, want %v", got, wantDeps)
+	}
+	if failure := animateError(t, conn); failure != "" {
+		t.Errorf("animate_error = %q after animating the repo", failure)
+	}
+}
+
+func TestClaimRepoOrder(t *testing.T) {
+	ctx, db, conn, _ := setup(t, graph(t))
+
+	// acme/app is pending since now, a repo which failed before and one
+	// pending for longer are added.
+	_, err := conn.Exec(`
+		INSERT INTO repos (owner_name, repo_name, last_ts, animate_error)
+		VALUES
+			('acme', 'failed', UNIXEPOCH() - 100, 'boom'),
+			('acme', 'old', UNIXEPOCH() - 50, '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claimed []string
+	for i := 0; i < 3; i++ {
+		row, err := db.ClaimRepo(ctx, database.ClaimRepoParams{LeaseID: "test", LeaseTimeout: 60})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed = append(claimed, row.RepoName)
+	}
+	if want := []string{"old", "app", "failed"}; !reflect.DeepEqual(claimed, want) {
+		t.Errorf("claimed %v, want %v", claimed, want)
+	}
+}
+
+func TestAnimateStopsWhenLeaseLost(t *testing.T) {
+	f := graph(t)
+	ctx, db, conn, client := setup(t, f)
+
+	// The lease of "test" expired and "other" took the repo over.
+	_, err := conn.Exec(`UPDATE repos SET lease_id = 'other', lease_ts = UNIXEPOCH()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m0 := "m0"
+	for _, cur := range []cursors{{}, {Manifest: &m0}} {
+		_, ok, err := animatePage(ctx, db, client, &policy.Policy{}, options{}, "test", "acme", "app", cur)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Fatalf("page %v: kept animating without the lease", cur)
+		}
+	}
+
+	mc, dc, animateTs := repoState(t, conn)
+	if mc.Valid || dc.Valid || animateTs != 0 {
+		t.Fatalf("state = %v, %v, %d, want untouched", mc, dc, animateTs)
+	}
+}
+
+func TestAnimateConcurrently(t *testing.T) {
+	f := graph(t)
+	ctx, db, conn, client := setup(t, f)
+
+	for _, name := range []string{"b", "c", "d", "e"} {
+		err := db.ReposUpsert(ctx, database.ReposUpsertParams{OwnerName: "acme", RepoName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wg, wctx := errgroup.WithContext(ctx)
+	for i := 0; i < 3; i++ {
+		wg.Go(func() error {
+			return animate(wctx, db, client, &policy.Policy{}, options{}, "test", time.Minute)
+		})
+	}
+	err := wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each repo is animated exactly once: three pages per repo.
+	if len(f.requests) != 15 {
+		t.Errorf("requests = %d, want 15", len(f.requests))
+	}
+
+	var pending, manifests int
+	err = conn.QueryRow(`SELECT COUNT(*) FROM repos WHERE animate_ts = 0 OR lease_id != ''`).Scan(&pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.QueryRow(`SELECT COUNT(*) FROM manifests`).Scan(&manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 || manifests != 10 {
+		t.Errorf("pending = %d, manifests = %d, want 0, 10", pending, manifests)
+	}
+}
+
+func TestCursorsNext(t *testing.T) {
+	m0 := "m0"
+	tests := []struct {
+		name      string
+		cur       cursors
+		manifests pageInfo
+		deps      pageInfo
+		want      cursors
+		wantOk    bool
+	}{
+		{
+			name:      "MoreDependencies",
+			cur:       cursors{Manifest: &m0},
+			manifests: pageInfo{EndCursor: "m1", HasNextPage: true},
+			deps:      pageInfo{EndCursor: "m1d0", HasNextPage: true},
+			want:      cursors{Manifest: &m0, Dep: strPtr("m1d0")},
+			wantOk:    true,
+		},
+		{
+			name:      "MoreDependenciesKeepScopes",
+			cur:       cursors{Manifest: &m0, Scopes: &manifestScopes{BlobPath: "go.mod"}},
+			manifests: pageInfo{EndCursor: "m1", HasNextPage: true},
+			deps:      pageInfo{EndCursor: "m1d0", HasNextPage: true},
+			want:      cursors{Manifest: &m0, Dep: strPtr("m1d0"), Scopes: &manifestScopes{BlobPath: "go.mod"}},
+			wantOk:    true,
+		},
+		{
+			name:      "NextManifestResetsScopes",
+			cur:       cursors{Manifest: &m0, Scopes: &manifestScopes{BlobPath: "go.mod"}},
+			manifests: pageInfo{EndCursor: "m1", HasNextPage: true},
+			deps:      pageInfo{EndCursor: "m1d0"},
+			want:      cursors{Manifest: strPtr("m1")},
+			wantOk:    true,
+		},
+		{
+			name:      "NextManifestResetsDependencies",
+			cur:       cursors{Manifest: &m0, Dep: strPtr("m1d0")},
+			manifests: pageInfo{EndCursor: "m1", HasNextPage: true},
+			deps:      pageInfo{EndCursor: "m1d1"},
+			want:      cursors{Manifest: strPtr("m1")},
+			wantOk:    true,
+		},
+		{
+			name:      "Done",
+			cur:       cursors{Manifest: &m0, Dep: strPtr("m1d0")},
+			manifests: pageInfo{EndCursor: "m1"},
+			deps:      pageInfo{EndCursor: "m1d1"},
+			want:      cursors{},
+			wantOk:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.cur.next(tt.manifests, tt.deps)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("next() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestAnimateFunding(t *testing.T) {
+	f := graph(t)
+	f.funding = map[string]string{
+		"alice": "github: [alice, '@zoe']\npatreon: alice\nko_fi:\n",
+		"bob":   "github: yan\ncustom: ['https://bob.example/donate']\n",
+		"carol": "github: [",
+	}
+	ctx, db, conn, client := setup(t, f)
+
+	err := animate(ctx, db, client, &policy.Policy{Deny: []policy.Rule{{Login: "yan"}}}, options{Funding: true}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.Query(`
+		SELECT owner_name || ':' || platform || ':' || handle
+		FROM funding_targets
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	targets := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, s)
+	}
+	wantTargets := []string{
+		"alice:github:alice",
+		"alice:github:zoe",
+		"alice:patreon:alice",
+		"bob:custom:https://bob.example/donate",
+		"bob:github:yan",
+	}
+	if !reflect.DeepEqual(targets, wantTargets) {
+		t.Errorf("funding targets = %v, want %v", targets, wantTargets)
+	}
+
+	// zoe is sponsored through alice's FUNDING.yml, yan is denied.
+	var recipients string
+	err = conn.QueryRow(`SELECT GROUP_CONCAT(recipient_id) FROM (SELECT recipient_id FROM donations ORDER BY 1)`).Scan(&recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipients != "alice,carol,dave,zoe" {
+		t.Errorf("donations = %s, want alice,carol,dave,zoe", recipients)
+	}
+}
+
+func TestAnimateFilters(t *testing.T) {
+	for _, f := range []filter{
+		{ExcludeManifests: []string{"package.json"}},
+		{IncludeManifests: []string{"**/go.mod"}},
+		{PackageManagers: []string{"go"}},
+	} {
+		ctx, db, conn, client := setup(t, graph(t))
+
+		err := animate(ctx, db, client, &policy.Policy{}, options{Filter: f}, "test", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Excluded dependencies are stored with the reason.
+		var excluded string
+		err = conn.QueryRow(`SELECT GROUP_CONCAT(dep_owner_name) FROM dependencies WHERE excluded_by != ''`).Scan(&excluded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if excluded != "dave" {
+			t.Errorf("%+v: excluded = %s, want dave", f, excluded)
+		}
+
+		var recipients string
+		err = conn.QueryRow(`SELECT GROUP_CONCAT(recipient_id) FROM (SELECT recipient_id FROM donations ORDER BY 1)`).Scan(&recipients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recipients != "alice,carol" {
+			t.Errorf("%+v: donations = %s, want alice,carol", f, recipients)
+		}
+	}
+}
+
+func TestAnimateRuntimeScope(t *testing.T) {
+	f := &fakeGraph{
+		t: t,
+		manifests: []fakeManifest{
+			{Filename: "package.json", Pages: [][]dep{
+				{{"alice", true}, {"bob", true}},
+				{{"carol", true}},
+			}},
+			{Filename: "go.mod", Pages: [][]dep{
+				{{"dave", true}},
+			}},
+		},
+		texts: map[string]string{
+			"package.json": `{"dependencies":{"alice/pkg":"1"},"devDependencies":{"bob/pkg":"1","carol/pkg":"1"}}`,
+		},
+	}
+	ctx, db, conn, client := setup(t, f)
+
+	err := animate(ctx, db, client, &policy.Policy{}, options{Filter: filter{RuntimeOnly: true}}, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each manifest is read once, not once per dependency page.
+	wantTexts := []string{"package.json", "go.mod"}
+	if !reflect.DeepEqual(f.textRequests, wantTexts) {
+		t.Errorf("manifest requests = %v, want %v", f.textRequests, wantTexts)
+	}
+
+	rows, err := conn.Query(`SELECT dep_owner_name, scope, excluded_by != '' FROM dependencies ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := []string{}
+	for rows.Next() {
+		var owner, scope string
+		var excluded bool
+		if err := rows.Scan(&owner, &scope, &excluded); err != nil {
+			t.Fatal(err)
+		}
+		if excluded {
+			scope += " excluded"
+		}
+		got = append(got, owner+":"+scope)
+	}
+	// The scope of go.mod dependencies isn't known, so they're kept.
+	want := []string{"alice:runtime", "bob:dev excluded", "carol:dev excluded", "dave:"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"package.json", "docs/package.json", true},
+		{"docs/**", "docs/site/package.json", true},
+		{"docs/**", "src/package.json", false},
+		{"**/test/**", "test/go.mod", true},
+		{"**/test/**", "a/b/test/c/go.mod", true},
+		{"*/go.mod", "a/b/go.mod", false},
+		{"requirements-*.txt", "requirements-dev.txt", true},
+	} {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDevPackages(t *testing.T) {
+	dev, all, ok := devPackages("web/package.json", `{"dependencies":{"react":"1"},"devDependencies":{"ESLint":"1"}}`)
+	if !ok || all || !dev["eslint"] || dev["react"] {
+		t.Errorf("package.json: dev = %v, all = %v, ok = %v", dev, all, ok)
+	}
+
+	dev, _, _ = devPackages("Cargo.toml", "[dependencies]\nserde = \"1\"\n\n[dev-dependencies]\ncriterion = \"1\"\n")
+	if !dev["criterion"] || dev["serde"] {
+		t.Errorf("Cargo.toml: dev = %v", dev)
+	}
+
+	dev, _, _ = devPackages("pyproject.toml", "[tool.poetry.dependencies]\nrequests = \"*\"\n[tool.poetry.group.test.dependencies]\npytest_cov = \"*\"\n")
+	if !dev["pytest-cov"] || dev["requests"] {
+		t.Errorf("pyproject.toml: dev = %v", dev)
+	}
+
+	if _, all, ok = devPackages("requirements-dev.txt", ""); !ok || !all {
+		t.Errorf("requirements-dev.txt: all = %v, ok = %v", all, ok)
+	}
+	if _, all, ok = devPackages("requirements.txt", ""); !ok || all {
+		t.Errorf("requirements.txt: all = %v, ok = %v", all, ok)
+	}
+	if _, _, ok = devPackages("go.mod", ""); ok {
+		t.Error("go.mod: ok = true, want unknown scope")
+	}
+}
@@ -0,0 +1,163 @@
+package animaterepos
+
+import (
+	"bufio"
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Scopes of a dependency. A dependency's scope is only known with
+// --scope=runtime and for the manifests devPackages understands.
+const (
+	scopeRuntime = "runtime"
+	scopeDev     = "dev"
+)
+
+// filter decides which dependencies are funded.
+type filter struct {
+	IncludeManifests []string
+	ExcludeManifests []string
+	// PackageManagers as reported by the dependency graph, eg. NPM or GO.
+	// Empty allows all.
+	PackageManagers []string
+	// RuntimeOnly excludes dev dependencies.
+	RuntimeOnly bool
+}
+
+// excludedBy returns why a dependency of the manifest at filename, with the
+// given package manager and scope, isn't funded. It's empty if it is.
+func (f filter) excludedBy(filename, packageManager, scope string) string {
+	for _, g := range f.ExcludeManifests {
+		if matchGlob(g, filename) {
+			return "manifest excluded by " + g
+		}
+	}
+	if len(f.IncludeManifests) > 0 {
+		included := false
+		for _, g := range f.IncludeManifests {
+			included = included || matchGlob(g, filename)
+		}
+		if !included {
+			return "manifest not included"
+		}
+	}
+	if len(f.PackageManagers) > 0 {
+		allowed := false
+		for _, pm := range f.PackageManagers {
+			allowed = allowed || strings.EqualFold(pm, packageManager)
+		}
+		if !allowed {
+			return "package manager " + packageManager + " not included"
+		}
+	}
+	if f.RuntimeOnly && scope == scopeDev {
+		return "dev dependency"
+	}
+	return ""
+}
+
+// matchGlob reports whether name matches the glob pattern. A * matches
+// within a path segment and ** any number of segments. Patterns without a
+// slash are matched against the base name.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	ok, _ := regexp.MatchString(re.String(), name)
+	return ok
+}
+
+var devRequirementsRe = regexp.MustCompile(`(?i)(^|[-_.])(dev|test|tests|docs|lint)([-_.]|$)`)
+
+// devPackages returns the dev dependencies declared in the manifest at
+// filename, keyed by normalized package name. ok is false if the manifest
+// isn't understood, in which case its scopes are unknown.
+func devPackages(filename, text string) (dev map[string]bool, all bool, ok bool) {
+	base := path.Base(filename)
+	switch {
+	case strings.HasSuffix(base, ".txt") && strings.Contains(base, "requirements"):
+		// Requirements files split by purpose, eg. requirements-dev.txt.
+		return nil, devRequirementsRe.MatchString(strings.TrimSuffix(base, ".txt")), true
+	case base == "package.json":
+		var v struct {
+			DevDependencies map[string]string
+		}
+		if json.Unmarshal([]byte(text), &v) != nil {
+			return nil, false, false
+		}
+		return keys(v.DevDependencies), false, true
+	case base == "composer.json":
+		var v struct {
+			RequireDev map[string]string `json:"require-dev"`
+		}
+		if json.Unmarshal([]byte(text), &v) != nil {
+			return nil, false, false
+		}
+		return keys(v.RequireDev), false, true
+	case base == "Cargo.toml":
+		return tomlTables(text, func(table string) bool {
+			return table == "dev-dependencies" || strings.HasSuffix(table, ".dev-dependencies")
+		}), false, true
+	case base == "pyproject.toml":
+		return tomlTables(text, func(table string) bool {
+			return table == "tool.poetry.dev-dependencies" ||
+				(strings.HasPrefix(table, "tool.poetry.group.") &&
+					strings.HasSuffix(table, ".dependencies") &&
+					table != "tool.poetry.group.main.dependencies")
+		}), false, true
+	}
+	return nil, false, false
+}
+
+// tomlTables returns the keys of the TOML tables isDev matches.
+func tomlTables(text string, isDev func(table string) bool) map[string]bool {
+	dev := map[string]bool{}
+	inDev := false
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			inDev = isDev(strings.Trim(line, "[] "))
+			continue
+		}
+		if key, _, ok := strings.Cut(line, "="); inDev && ok && !strings.HasPrefix(line, "#") {
+			dev[normalizePackage(strings.Trim(strings.TrimSpace(key), `"'`))] = true
+		}
+	}
+	return dev
+}
+
+func keys(m map[string]string) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[normalizePackage(k)] = true
+	}
+	return out
+}
+
+// normalizePackage makes package names comparable across manifests and the
+// dependency graph, eg. PyPI treats Foo_Bar and foo-bar as the same.
+func normalizePackage(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
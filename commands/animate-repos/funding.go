@@ -0,0 +1,114 @@
+package animaterepos
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+// fundingTarget is a platform and handle declared in a FUNDING.yml file,
+// eg. github and alecthomas or custom and https://example.com/donate.
+type fundingTarget struct {
+	Platform string
+	Handle   string
+}
+
+// parseFunding parses the contents of a FUNDING.yml file. Each platform
+// maps to a single handle or a list of them, empty handles are skipped.
+func parseFunding(text string) ([]fundingTarget, error) {
+	var platforms map[string]any
+	err := yaml.Unmarshal([]byte(text), &platforms)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid FUNDING.yml")
+	}
+
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var targets []fundingTarget
+	for _, name := range names {
+		var handles []any
+		switch v := platforms[name].(type) {
+		case []any:
+			handles = v
+		default:
+			handles = []any{v}
+		}
+		for _, h := range handles {
+			s, ok := h.(string)
+			if !ok {
+				continue
+			}
+			s = strings.TrimSpace(s)
+			if name == "github" {
+				s = strings.TrimPrefix(s, "@")
+			}
+			if s == "" {
+				continue
+			}
+			targets = append(targets, fundingTarget{
+				Platform: strings.ToLower(strings.TrimSpace(name)),
+				Handle:   s,
+			})
+		}
+	}
+	return targets, nil
+}
+
+// recordFunding replaces the funding targets of the repo with the ones
+// declared in its FUNDING.yml text, which is empty if it has none. An
+// invalid file is logged and treated as declaring no targets.
+func recordFunding(ctx context.Context, db *database.DB, ownerName, repoName, text string) ([]fundingTarget, error) {
+	targets, err := parseFunding(text)
+	if err != nil {
+		log.FromContext(ctx).WithError(err).Warnf("ignoring FUNDING.yml of %s/%s", ownerName, repoName)
+		targets = nil
+	}
+
+	err = db.Tx(ctx, func(q *database.Queries) error {
+		/* autoquery name: DeleteFundingTargets :exec
+
+		DELETE FROM funding_targets
+		WHERE owner_name = ? AND repo_name = ?;
+		*/
+		err := q.DeleteFundingTargets(ctx, database.DeleteFundingTargetsParams{
+			OwnerName: ownerName,
+			RepoName:  repoName,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, t := range targets {
+			/* autoquery name: InsertFundingTarget :exec
+
+			INSERT INTO funding_targets (owner_name, repo_name, platform, handle, last_ts)
+			VALUES (?, ?, ?, ?, UNIXEPOCH())
+			ON CONFLICT (owner_name, repo_name, platform, handle)
+			DO UPDATE SET last_ts = excluded.last_ts;
+			*/
+			err = q.InsertFundingTarget(ctx, database.InsertFundingTargetParams{
+				OwnerName: ownerName,
+				RepoName:  repoName,
+				Platform:  t.Platform,
+				Handle:    t.Handle,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to record funding targets of %s/%s", ownerName, repoName)
+	}
+	return targets, nil
+}
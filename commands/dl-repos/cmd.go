@@ -1,19 +1,67 @@
 //go:generate autoquery
 package dlrepos
 
+//
+// Repos are listed per entity, organisations through the org endpoint so
+// repos on teams the token's user isn't on are included, and users through
+// the user endpoint. Private repos of a user are only listed if the token
+// belongs to that user. The same filters apply to every entity:
+//	- --visibility: public, private (which includes internal) or all;
+//	- --no-forks leaves out forks, and archived repos are left out unless
+//	  --archived is given;
+//	- --include and --exclude match the repo's name, or its full name if
+//	  the glob has a slash;
+//	- --topics keeps repos with at least one of the topics and
+//	  --exclude-topics leaves out repos with any of them.
+//
+// Re-running dl-repos keeps the repos table in sync with GitHub:
+//	- repos pushed since their last animation get a new last_ts, so
+//	  animate-repos animates them again;
+//	- repos left out by the filters, and repos of the entity which
+//	  aren't listed anymore, are marked inactive with
+//	  the reason in inactive_reason and when in inactive_ts. Inactive
+//	  repos aren't animated, and prune treats their dependencies as gone
+//	  from then on;
+//	- inactive repos which are listed and pass the filters again are
+//	  reactivated and re-animated.
+// Repos only animate-local added, which GitHub never listed, are left
+// alone.
+//
+
 import (
 	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/errors"
 	"github.com/google/go-github/v55/github"
 	utils "github.com/thnxdev/utils"
 	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
 	"github.com/thnxdev/utils/utils/log"
 )
 
 type CmdDlRepos struct {
 	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
-	Entities             []utils.Entity      `help:"The GitHub entities to import for sponsorships." required:""`
+	Entities             []utils.Entity      `help:"The GitHub entities to import for sponsorships (required)."`
+	Visibility           string              `help:"Visibility of the repos to import (${enum})." enum:"all,public,private" default:"all"`
+	Forks                bool                `help:"Import forks." default:"true" negatable:""`
+	Archived             bool                `help:"Import archived repos."`
+	Include              []string            `help:"Only import repos whose name matches one of these globs, eg. 'api-*' or 'acme/*'."`
+	Exclude              []string            `help:"Don't import repos whose name matches one of these globs."`
+	Topics               []string            `help:"Only import repos with at least one of these topics."`
+	ExcludeTopics        []string            `help:"Don't import repos with any of these topics."`
+}
+
+// Validate requires --entities. It isn't a required flag so run can embed
+// the command with the stage disabled and no entities.
+func (c *CmdDlRepos) Validate() error {
+	if len(c.Entities) == 0 {
+		return errors.New("missing flags: --entities")
+	}
+	return nil
 }
 
 func (c *CmdDlRepos) Run(
@@ -23,57 +71,213 @@ func (c *CmdDlRepos) Run(
 	logger := log.FromContext(ctx)
 	logger.Info("starting")
 
-	nextPage := 0
+	transport := httpgh.NewTransport(nil)
+	client := github.NewClient(
+		&http.Client{Transport: transport},
+	).WithAuthToken(string(c.GhClassicAccessToken))
 
-	for {
-		client := github.NewClient(nil).WithAuthToken(string(c.GhClassicAccessToken))
-		repos, resp, err := client.Repositories.List(ctx, "", &github.RepositoryListOptions{
-			ListOptions: github.ListOptions{
-				PerPage: 100,
-				Page:    nextPage,
-			},
-		})
+	self, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to get authenticated user")
+	}
+
+	for _, e := range c.Entities {
+		start := time.Now().Unix()
+
+		repos, err := listRepos(ctx, client, string(e), self.GetLogin())
 		if err != nil {
-			return errors.Wrap(err, "failed to get repositories")
+			return err
 		}
 
 		for _, r := range repos {
-			validEntity := false
-			for _, e := range c.Entities {
-				if *r.Owner.Login == string(e) {
-					validEntity = true
-					break
-				}
-			}
+			if reason := c.ignore(r); reason != "" {
+				logger.Infof("%s ignored: %s", r.GetFullName(), reason)
 
-			if !validEntity {
-				logger.Infof("%s ignored", *r.FullName)
+				/* autoquery name: RepoDeactivate :exec
+
+				UPDATE repos
+				SET
+					inactive_reason = ?,
+					sync_ts = UNIXEPOCH(),
+					inactive_ts = CASE WHEN inactive_reason = '' THEN UNIXEPOCH() ELSE inactive_ts END
+				WHERE owner_name = ? AND repo_name = ?;
+				*/
+				err := db.RepoDeactivate(ctx, database.RepoDeactivateParams{
+					InactiveReason: reason,
+					OwnerName:      r.GetOwner().GetLogin(),
+					RepoName:       r.GetName(),
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to deactivate repo")
+				}
 				continue
 			}
 
-			logger.Infof("%s added", *r.FullName)
+			logger.Infof("%s added", r.GetFullName())
 
-			/* autoquery name: ReposInsert :exec
+			/* autoquery name: ReposUpsert :exec
 
-			INSERT INTO repos (owner_name, repo_name, last_ts)
-			VALUES (?, ?, UNIXEPOCH())
+			INSERT INTO repos (owner_name, repo_name, last_ts, pushed_ts, sync_ts)
+			VALUES (?, ?, UNIXEPOCH(), ?, UNIXEPOCH())
 			ON CONFLICT (owner_name, repo_name)
-			DO NOTHING;
+			DO UPDATE SET
+				last_ts = CASE
+					WHEN excluded.pushed_ts > repos.animate_ts OR repos.inactive_reason != '' THEN excluded.last_ts
+					ELSE repos.last_ts
+				END,
+				pushed_ts = excluded.pushed_ts,
+				sync_ts = excluded.sync_ts,
+				is_local = FALSE,
+				inactive_reason = '';
 			*/
-			err := db.ReposInsert(ctx, database.ReposInsertParams{
-				OwnerName: *r.Owner.Login,
-				RepoName:  *r.Name,
+			var pushedTs int64
+			if r.PushedAt != nil {
+				pushedTs = r.PushedAt.Unix()
+			}
+			err := db.ReposUpsert(ctx, database.ReposUpsertParams{
+				OwnerName: r.GetOwner().GetLogin(),
+				RepoName:  r.GetName(),
+				PushedTs:  pushedTs,
 			})
 			if err != nil {
-				return errors.Wrap(err, "failed to insert repos")
+				return errors.Wrap(err, "failed to upsert repo")
 			}
 		}
 
-		if resp.NextPage == 0 {
-			break
+		/* autoquery name: ReposDeactivateVanished :execrows
+
+		UPDATE repos
+		SET inactive_reason = 'vanished', inactive_ts = UNIXEPOCH()
+		WHERE
+			owner_name = ? COLLATE NOCASE AND
+			NOT is_local AND
+			sync_ts < ? AND
+			inactive_reason = '';
+		*/
+		vanished, err := db.ReposDeactivateVanished(ctx, database.ReposDeactivateVanishedParams{
+			OwnerName: string(e),
+			SyncTs:    start,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to deactivate vanished repos")
+		}
+		if vanished > 0 {
+			logger.Infof("%d repos of %s vanished", vanished, e)
 		}
-		nextPage = resp.NextPage
 	}
 
+	remaining, reset := transport.Remaining()
+	logger.Infof("github rate limit: %d remaining until %s", remaining, reset)
+
 	return nil
 }
+
+// listRepos returns every repo owned by the entity, which is an org or a
+// user. self is the login of the token's user.
+func listRepos(ctx context.Context, client *github.Client, entity, self string) ([]*github.Repository, error) {
+	user, _, err := client.Users.Get(ctx, entity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get entity %s", entity)
+	}
+
+	var all []*github.Repository
+	opts := github.ListOptions{PerPage: 100}
+	for {
+		var (
+			repos []*github.Repository
+			resp  *github.Response
+		)
+		switch {
+		case user.GetType() == "Organization":
+			repos, resp, err = client.Repositories.ListByOrg(ctx, entity, &github.RepositoryListByOrgOptions{
+				ListOptions: opts,
+			})
+		case strings.EqualFold(entity, self):
+			// Only the authenticated user endpoint includes private repos.
+			repos, resp, err = client.Repositories.List(ctx, "", &github.RepositoryListOptions{
+				Affiliation: "owner",
+				ListOptions: opts,
+			})
+		default:
+			repos, resp, err = client.Repositories.List(ctx, entity, &github.RepositoryListOptions{
+				Type:        "owner",
+				ListOptions: opts,
+			})
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get repositories of %s", entity)
+		}
+		all = append(all, repos...)
+
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ignore returns why the repo is left out by the filters, or "" if it's
+// imported.
+func (c *CmdDlRepos) ignore(r *github.Repository) string {
+	public := r.GetVisibility() == "public" || (r.GetVisibility() == "" && !r.GetPrivate())
+	switch {
+	case c.Visibility == "public" && !public:
+		return "not public"
+	case c.Visibility == "private" && public:
+		return "not private"
+	case !c.Forks && r.GetFork():
+		return "fork"
+	case !c.Archived && r.GetArchived():
+		return "archived"
+	}
+
+	for _, g := range c.Exclude {
+		if matchName(g, r) {
+			return "excluded by " + g
+		}
+	}
+	if len(c.Include) > 0 {
+		included := false
+		for _, g := range c.Include {
+			included = included || matchName(g, r)
+		}
+		if !included {
+			return "not included"
+		}
+	}
+
+	for _, t := range c.ExcludeTopics {
+		if hasTopic(r, t) {
+			return "excluded topic " + t
+		}
+	}
+	if len(c.Topics) == 0 {
+		return ""
+	}
+	for _, t := range c.Topics {
+		if hasTopic(r, t) {
+			return ""
+		}
+	}
+	return "no matching topic"
+}
+
+// matchName reports whether the repo's name, or its full name if the glob
+// has a slash, matches the glob.
+func matchName(glob string, r *github.Repository) bool {
+	name := r.GetName()
+	if strings.Contains(glob, "/") {
+		name = r.GetFullName()
+	}
+	ok, _ := path.Match(strings.ToLower(glob), strings.ToLower(name))
+	return ok
+}
+
+func hasTopic(r *github.Repository, topic string) bool {
+	for _, t := range r.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
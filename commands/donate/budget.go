@@ -0,0 +1,250 @@
+package donate
+
+import (
+	"context"
+	"sort"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/database"
+)
+
+// minSponsorshipAmount is the smallest amount in USD GitHub accepts for a
+// custom sponsorship.
+const minSponsorshipAmount = 1
+
+// getAmounts returns the amount to donate for each donable row keyed by
+// donation id. Rows with a target amount (eg. imported) get it as is,
+// taken first from the sponsor's budget in the order the rows were added.
+// Rows which don't fit in the sponsor's remaining monthly budget are left
+// out of the result. The returned budget tracks what's left of each
+// sponsor's budget as the donations are made.
+//
+// What a sponsor already spent this month is every successful donation
+// in the donation_events ledger since monthStart, plus every active
+// recurring sponsorship, as GitHub charges those monthly whenever they
+// were created. Recurring sponsorships are taken from reconcile's
+// sponsorships table, or from the donations table for recipients
+// reconcile hasn't seen yet.
+func (c *CmdDonate) getAmounts(
+	ctx context.Context,
+	db *database.DB,
+	rows []database.GetDonablesRow,
+	monthStart int64,
+) (map[int64]int, *budgets, error) {
+	amounts := map[int64]int{}
+
+	if c.MonthlyBudget <= 0 {
+		for _, row := range rows {
+			if row.TargetAmount.Valid {
+				amounts[row.ID] = int(row.TargetAmount.Int64)
+				continue
+			}
+			amounts[row.ID] = c.Amount * c.weight(row)
+		}
+		return amounts, newBudgets(rows, amounts, nil), nil
+	}
+
+	/* autoquery name: GetDonatedThisMonth :many
+
+	WITH
+		args AS (
+			SELECT
+				CAST(sqlc.arg(month_start_ts) AS INTEGER) AS month_start_ts,
+				CAST(sqlc.arg(is_recurring) AS BOOLEAN) AS is_recurring
+		),
+		recurring AS (
+			SELECT s.sponsor_id, s.recipient_id, s.amount
+			FROM sponsorships s
+			WHERE s.is_active AND NOT s.is_one_time
+			UNION ALL
+			SELECT d.sponsor_id, d.recipient_id, d.amount
+			FROM donations d, args
+			WHERE
+				d.donate_ts > 0 AND
+				d.cancel_ts = 0 AND
+				COALESCE(d.is_recurring, args.is_recurring) AND
+				NOT EXISTS (
+					SELECT 1
+					FROM sponsorships s
+					WHERE
+						s.sponsor_id = d.sponsor_id AND
+						s.recipient_id = d.recipient_id COLLATE NOCASE
+				)
+		)
+	SELECT sponsor_id, CAST(SUM(amount) AS INTEGER) AS total
+	FROM (
+		SELECT sponsor_id, amount
+		FROM recurring
+		UNION ALL
+		SELECT e.sponsor_id, e.amount
+		FROM donation_events e, args
+		WHERE
+			e.outcome = 'success' AND
+			e.ts >= args.month_start_ts AND
+			NOT (
+				e.is_recurring AND
+				EXISTS (
+					SELECT 1
+					FROM recurring r
+					WHERE
+						r.sponsor_id = e.sponsor_id AND
+						r.recipient_id = e.recipient_id COLLATE NOCASE
+				)
+			)
+	)
+	GROUP BY sponsor_id;
+	*/
+	donated, err := db.GetDonatedThisMonth(ctx, database.GetDonatedThisMonthParams{
+		MonthStartTs: monthStart,
+		IsRecurring:  c.IsRecurring,
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get donated totals")
+	}
+	spent := map[string]int{}
+	left := map[string]int{}
+	for _, d := range donated {
+		spent[d.SponsorID] = int(d.Total)
+	}
+	for _, row := range rows {
+		left[row.SponsorID] = c.MonthlyBudget - spent[row.SponsorID]
+	}
+
+	fixed := map[string][]database.GetDonablesRow{}
+	bySponsor := map[string][]share{}
+	for _, row := range rows {
+		if row.TargetAmount.Valid {
+			fixed[row.SponsorID] = append(fixed[row.SponsorID], row)
+			continue
+		}
+		bySponsor[row.SponsorID] = append(bySponsor[row.SponsorID], share{
+			ID:        row.ID,
+			Recipient: row.RecipientID,
+			Weight:    c.weight(row),
+		})
+	}
+
+	for sponsor, rows := range fixed {
+		for _, row := range rows {
+			if int(row.TargetAmount.Int64) <= c.MonthlyBudget-spent[sponsor] {
+				amounts[row.ID] = int(row.TargetAmount.Int64)
+				spent[sponsor] += int(row.TargetAmount.Int64)
+			}
+		}
+	}
+
+	for sponsor, shares := range bySponsor {
+		budget := c.MonthlyBudget - spent[sponsor]
+		for id, amount := range splitBudget(budget, shares) {
+			amounts[id] = amount
+		}
+	}
+
+	return amounts, newBudgets(rows, amounts, left), nil
+}
+
+// budgets is what's left of each sponsor's monthly budget during a run.
+// Tiers may cost more than a row's amount, so a row may only use what's
+// left once the amounts of the sponsor's rows still to come are set aside.
+type budgets struct {
+	left     map[string]int // nil without a monthly budget
+	reserved map[string]int
+}
+
+func newBudgets(rows []database.GetDonablesRow, amounts map[int64]int, left map[string]int) *budgets {
+	b := &budgets{left: left, reserved: map[string]int{}}
+	for _, row := range rows {
+		b.reserved[row.SponsorID] += amounts[row.ID]
+	}
+	return b
+}
+
+// claim releases the amount set aside for row and returns the most the
+// row may cost, or -1 if there's no limit.
+func (b *budgets) claim(row database.GetDonablesRow, amount int) int {
+	b.reserved[row.SponsorID] -= amount
+	if b.left == nil {
+		return -1
+	}
+	return b.left[row.SponsorID] - b.reserved[row.SponsorID]
+}
+
+// spend takes amount off sponsor's budget.
+func (b *budgets) spend(sponsor string, amount int) {
+	if b.left != nil {
+		b.left[sponsor] -= amount
+	}
+}
+
+// weight returns the relative size of the donation for row. Rows without
+// any recorded dependents (eg. imported from csv) count as one.
+func (c *CmdDonate) weight(row database.GetDonablesRow) int {
+	if c.Weighting != "dependents" || row.Dependents < 1 {
+		return 1
+	}
+	return int(row.Dependents)
+}
+
+// share is a recipient's claim on a sponsor's budget.
+type share struct {
+	ID        int64
+	Recipient string
+	Weight    int
+}
+
+// splitBudget splits budget across shares in proportion to their weights,
+// keyed by donation id. The result is deterministic and never exceeds
+// budget:
+//   - shares are ordered by weight (heaviest first) then recipient login;
+//   - when the budget can't cover the minimum amount for everyone only the
+//     first shares that fit are included;
+//   - each included share gets the minimum amount and the rest is split by
+//     largest remainder, ties going to the earlier share.
+func splitBudget(budget int, shares []share) map[int64]int {
+	amounts := map[int64]int{}
+
+	sorted := append([]share{}, shares...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weight != sorted[j].Weight {
+			return sorted[i].Weight > sorted[j].Weight
+		}
+		return sorted[i].Recipient < sorted[j].Recipient
+	})
+
+	n := len(sorted)
+	if fit := budget / minSponsorshipAmount; fit < n {
+		n = fit
+	}
+	if n <= 0 {
+		return amounts
+	}
+	sorted = sorted[:n]
+
+	totalWeight := 0
+	for _, s := range sorted {
+		totalWeight += s.Weight
+	}
+
+	rest := budget - n*minSponsorshipAmount
+	remainders := make([]int, n)
+	allocated := 0
+	for i, s := range sorted {
+		extra := rest * s.Weight / totalWeight
+		remainders[i] = rest * s.Weight % totalWeight
+		amounts[s.ID] = minSponsorshipAmount + extra
+		allocated += extra
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for _, i := range order[:rest-allocated] {
+		amounts[sorted[i].ID]++
+	}
+
+	return amounts
+}
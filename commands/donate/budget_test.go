@@ -0,0 +1,168 @@
+package donate
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/thnxdev/utils/database"
+)
+
+func TestSplitBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int
+		shares []share
+		want   map[int64]int
+	}{
+		{
+			name:   "remainder goes to the first shares in order",
+			budget: 10,
+			shares: []share{{3, "c", 1}, {1, "a", 1}, {2, "b", 1}},
+			want:   map[int64]int{1: 4, 2: 3, 3: 3},
+		},
+		{
+			name:   "remainder goes to the largest remainder",
+			budget: 10,
+			shares: []share{{1, "a", 1}, {2, "b", 2}},
+			want:   map[int64]int{1: 4, 2: 6},
+		},
+		{
+			name:   "budget under the minimum for everyone",
+			budget: 2,
+			shares: []share{{3, "c", 1}, {2, "b", 1}, {1, "a", 1}},
+			want:   map[int64]int{1: 1, 2: 1},
+		},
+		{
+			name:   "heaviest shares fit first",
+			budget: 1,
+			shares: []share{{1, "a", 1}, {2, "b", 3}},
+			want:   map[int64]int{2: 1},
+		},
+		{
+			name:   "no budget",
+			budget: 0,
+			shares: []share{{1, "a", 1}},
+			want:   map[int64]int{},
+		},
+		{
+			name:   "overspent",
+			budget: -5,
+			shares: []share{{1, "a", 1}},
+			want:   map[int64]int{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitBudget(test.budget, test.shares)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitBudget(%d) = %v, want %v", test.budget, got, test.want)
+			}
+			total := 0
+			for _, amount := range got {
+				total += amount
+			}
+			if len(got) > 0 && total > test.budget {
+				t.Errorf("splitBudget(%d) spent %d", test.budget, total)
+			}
+		})
+	}
+}
+
+func target(amount int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: amount, Valid: true}
+}
+
+func TestGetAmounts(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	// 2 of the budget were donated this month.
+	_, err := conn.Exec(`
+		INSERT INTO donation_events (ts, sponsor_id, recipient_id, amount, is_recurring, privacy_level, outcome)
+		VALUES (UNIXEPOCH(), 'acme', 'old', 2, FALSE, 'PUBLIC', 'success')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []database.GetDonablesRow{
+		{ID: 1, SponsorID: "acme", RecipientID: "a", TargetAmount: target(6)},
+		{ID: 2, SponsorID: "acme", RecipientID: "b", TargetAmount: target(5)},
+		{ID: 3, SponsorID: "acme", RecipientID: "c", TargetAmount: target(2)},
+		{ID: 4, SponsorID: "acme", RecipientID: "d"},
+		{ID: 5, SponsorID: "other", RecipientID: "e"},
+		{ID: 6, SponsorID: "other", RecipientID: "f"},
+	}
+
+	c := &CmdDonate{MonthlyBudget: 10, Weighting: "equal"}
+	amounts, budget, err := c.getAmounts(ctx, db, rows, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The target amounts which fit exhaust acme's budget, in order.
+	want := map[int64]int{1: 6, 3: 2, 5: 5, 6: 5}
+	if !reflect.DeepEqual(amounts, want) {
+		t.Errorf("amounts = %v, want %v", amounts, want)
+	}
+
+	// A row may cost what's left once the later rows are set aside.
+	if limit := budget.claim(rows[0], 6); limit != 6 {
+		t.Errorf("limit = %d, want 6", limit)
+	}
+	budget.spend("acme", 6)
+	if limit := budget.claim(rows[2], 2); limit != 2 {
+		t.Errorf("limit = %d, want 2", limit)
+	}
+
+	// Without a monthly budget every row gets its amount and no limit.
+	c = &CmdDonate{Amount: 3, Weighting: "equal"}
+	amounts, budget, err = c.getAmounts(ctx, db, rows, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[int64]int{1: 6, 2: 5, 3: 2, 4: 3, 5: 3, 6: 3}
+	if !reflect.DeepEqual(amounts, want) {
+		t.Errorf("amounts = %v, want %v", amounts, want)
+	}
+	if limit := budget.claim(rows[0], 6); limit != -1 {
+		t.Errorf("limit = %d, want -1", limit)
+	}
+}
+
+func TestPickTier(t *testing.T) {
+	tiers := []tier{
+		{Name: "custom", MonthlyPriceInDollars: 1, IsCustomAmount: true},
+		{Name: "5", MonthlyPriceInDollars: 5},
+		{Name: "10", MonthlyPriceInDollars: 10},
+		{Name: "once 7", MonthlyPriceInDollars: 7, IsOneTime: true},
+	}
+	tests := []struct {
+		tiers       string
+		amount      int
+		limit       int
+		isRecurring bool
+		want        string
+	}{
+		{"closest", 7, -1, true, "5"},
+		{"closest", 8, -1, true, "10"},
+		{"closest", 7, 9, true, "5"},
+		{"closest", 9, 9, true, "5"},
+		{"closest", 9, 10, true, "10"},
+		{"closest", 3, 4, true, ""},
+		{"closest", 1, -1, false, "once 7"},
+		{"at-least", 5, -1, true, "5"},
+		{"at-least", 6, -1, true, "10"},
+		{"at-least", 6, 10, true, "10"},
+		{"at-least", 6, 9, true, ""},
+		{"at-least", 11, -1, true, ""},
+		{"custom", 5, -1, true, ""},
+	}
+	for _, test := range tests {
+		c := &CmdDonate{Tiers: test.tiers}
+		got, ok := c.pickTier(tiers, test.amount, test.limit, test.isRecurring)
+		if ok != (test.want != "") || got.Name != test.want {
+			t.Errorf("pickTier(%s, %d, %d, %v) = %q, %v, want %q", test.tiers, test.amount, test.limit, test.isRecurring, got.Name, ok, test.want)
+		}
+	}
+}
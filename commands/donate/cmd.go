@@ -5,21 +5,30 @@ package donate
 // This worker continuously checks to see if there are any outstanding
 // donations and initiates a createSponsorship GH GraphQL call for each.
 // An outstanding donation is one which:
-// 	- donate_ts is before donable_ts;
-//	- donate_ts is before 1st of the current month;
-// This results in a monthly donation to the project.
+// 	- donate_ts is before last_ts, ie. it was never donated;
+//	- or, for one-time donations, donate_ts is before the start of the
+//	  current --period in --timezone;
+//	- and isn't backing off from a failed attempt, failed permanently,
+//	  cancelled by prune or covered by a sponsorship reconcile found.
+// A retry first checks GitHub for a sponsorship an interrupted attempt
+// may have created. Only the run holding the donate lease donates.
 //
 
 import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/alecthomas/errors"
 	"github.com/shurcooL/githubv4"
 	utils "github.com/thnxdev/utils"
 	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
 	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
 	"golang.org/x/oauth2"
 )
 
@@ -27,32 +36,143 @@ type CmdDonate struct {
 	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
 	Amount               int                 `help:"The amount to donate to each dependency." default:"1"`
 	IsRecurring          bool                `help:"Whether the donation should be recurring monthly." default:"true"`
+	MonthlyBudget        int                 `help:"The total monthly amount per sponsor to split across its dependencies. Overrides --amount."`
+	Weighting            string              `help:"How to weight each dependency (${enum})." enum:"equal,dependents" default:"equal"`
+	DryRun               bool                `help:"Print the donation plan without creating any sponsorships."`
+	PlanPath             string              `help:"Write the dry-run plan to this csv file." type:"path"`
+	Period               string              `help:"How often one-time donations are repeated (${enum})." enum:"month,week,day" default:"month"`
+	Timezone             string              `help:"The timezone periods and monthly budgets start in." default:"UTC"`
+	RetryFailed          bool                `help:"Retry donations which previously failed permanently."`
+	Tiers                string              `help:"How to pick the recipient's sponsors tier (${enum}). custom donates the amount as is, closest uses the tier nearest to it and at-least the cheapest tier of at least the amount." enum:"custom,closest,at-least" default:"custom"`
+	LeaseTimeout         time.Duration       `help:"How long the donate lease of a run that stopped renewing it blocks other runs." default:"10m"`
+}
+
+// Validate rejects lease timeouts under a second, which the lease is stored
+// in.
+func (c *CmdDonate) Validate() error {
+	if c.LeaseTimeout < time.Second {
+		return errors.Errorf("lease timeout must be at least 1s, got %s", c.LeaseTimeout)
+	}
+	return nil
 }
 
 func (c *CmdDonate) Run(
 	ctx context.Context,
 	db *database.DB,
+	pol *policy.Policy,
 ) error {
 	logger := log.FromContext(ctx)
 	logger.Info("starting")
 
+	if c.PlanPath != "" && !c.DryRun {
+		return errors.New("--plan-path requires --dry-run")
+	}
+
+	// donateCtx is cancelled if the lease is lost, which stops the run
+	// before it creates any more sponsorships.
+	donateCtx := ctx
+	if !c.DryRun {
+		lctx, release, err := acquireLease(ctx, db, c.LeaseTimeout)
+		if err != nil {
+			return err
+		}
+		defer release()
+		donateCtx = lctx
+	}
+
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	)
+
 	client := githubv4.NewClient(
 		oauth2.NewClient(
-			ctx,
+			hctx,
 			oauth2.StaticTokenSource(&oauth2.Token{
 				AccessToken: string(c.GhClassicAccessToken),
 			}),
 		),
 	)
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return errors.Wrapf(err, "invalid timezone %q", c.Timezone)
+	}
+	now := time.Now().In(loc)
+
+	if c.RetryFailed {
+		/* autoquery name: ResetFailedDonations :execrows
+
+		UPDATE donations
+		SET failed_ts = 0, failure = '', attempts = 0, donate_attempt_ts = 0
+		WHERE failed_ts > 0;
+		*/
+		reset, err := db.ResetFailedDonations(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to reset failed donations")
+		}
+		logger.Infof("retrying %d failed donations", reset)
+	}
+
 	/* autoquery name: GetDonables :many
 
-	SELECT id, sponsor_id, recipient_id
-	FROM donations
+	SELECT
+		d.id,
+		d.sponsor_id,
+		d.recipient_id,
+		d.target_amount,
+		CAST(COALESCE(d.is_recurring, args.is_recurring) AS BOOLEAN) AS is_recurring,
+		CAST(COALESCE(
+			d.privacy_level,
+			(SELECT sd.privacy_level FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+			'PUBLIC'
+		) AS TEXT) AS privacy_level,
+		CAST(COALESCE(
+			d.receive_emails,
+			(SELECT sd.receive_emails FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+			FALSE
+		) AS BOOLEAN) AS receive_emails,
+		CAST((
+			SELECT COUNT(DISTINCT dd.repo_name)
+			FROM donation_dependents dd
+			WHERE
+				dd.sponsor_id = d.sponsor_id AND
+				dd.recipient_id = d.recipient_id
+		) AS INTEGER) AS dependents,
+		d.unconfirmed_ts
+	FROM donations d, (
+		SELECT
+			CAST(sqlc.arg(is_recurring) AS BOOLEAN) AS is_recurring,
+			CAST(sqlc.arg(since_ts) AS INTEGER) AS since_ts
+	) args
 	WHERE
-		donate_ts < last_ts AND
-		donate_attempt_ts < UNIXEPOCH() - 3600;
+		(
+			d.donate_ts < d.last_ts OR
+			(
+				NOT COALESCE(d.is_recurring, args.is_recurring) AND
+				d.donate_ts < args.since_ts
+			)
+		) AND
+		d.donate_attempt_ts + MIN(3600 << MIN(MAX(d.attempts - 1, 0), 10), 604800) < UNIXEPOCH() AND
+		d.failed_ts = 0 AND
+		d.cancel_ts = 0 AND
+		NOT EXISTS (
+			SELECT 1
+			FROM sponsorships s
+			WHERE
+				s.sponsor_id = d.sponsor_id AND
+				s.recipient_id = d.recipient_id COLLATE NOCASE AND
+				s.is_active AND
+				NOT s.is_one_time
+		)
+	ORDER BY d.id;
 	*/
-	rows, err := db.GetDonables(ctx)
+	since := PeriodStart(now, c.Period)
+	rows, err := db.GetDonables(ctx, database.GetDonablesParams{
+		IsRecurring: c.IsRecurring,
+		SinceTs:     since.Unix(),
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
@@ -60,45 +180,85 @@ func (c *CmdDonate) Run(
 		return errors.Wrap(err, "failed to get donable rows")
 	}
 
-	amount := githubv4.Int(c.Amount)
-	isRecurring := githubv4.Boolean(c.IsRecurring)
-	privacyLevel := githubv4.SponsorshipPrivacy(githubv4.SponsorshipPrivacyPublic)
-	receiveEmails := githubv4.Boolean(false)
+	rows = applyPolicy(ctx, client, pol, rows)
+
+	amounts, budget, err := c.getAmounts(ctx, db, rows, PeriodStart(now, "month").Unix())
+	if err != nil {
+		return err
+	}
+
+	if c.DryRun {
+		return c.dryRun(ctx, db, client, rows, amounts, budget)
+	}
 
 	sponsorIds := map[string]string{}
+	tiers := map[string][]tier{}
 
 	// For each recipient create a GH sponsorship that is:
-	//	- $1
+	//	- $1 or its share of the monthly budget, or the matching tier
 	//	- recurring
-	//	- is public
+	//	- public or private, with or without emails, as set for the row
+	//	  or its sponsor (see sponsor-defaults)
 	for _, row := range rows {
 		row := row
-		logger.Infof("donating %s:%s", row.SponsorID, row.RecipientID)
+		rowAmount, ok := amounts[row.ID]
+		if !ok {
+			logger.Infof("deferring %s:%s, monthly budget exhausted", row.SponsorID, row.RecipientID)
+			continue
+		}
+		logger.Infof("donating %s:%s ($%d)", row.SponsorID, row.RecipientID, rowAmount)
+		limit := budget.claim(row, rowAmount)
 
-		failed := false
+		mctx, gqlErrs := httpgh.WithGraphQLErrors(donateCtx)
+		sid, err := getSponsorID(mctx, client, sponsorIds, row.SponsorID)
+		if err != nil {
+			logger.WithError(err).Error("failed to get sponsor id")
+		}
 
-		sid, ok := sponsorIds[row.SponsorID]
-		if !ok {
-			var q struct {
-				RepositoryOwner struct {
-					ID string
-				} `graphql:"repositoryOwner(login: $login)"`
-			}
-			var vars map[string]any = map[string]any{
-				"login": githubv4.String(row.SponsorID),
+		var t tier
+		if err == nil && c.Tiers != "custom" {
+			t, err = c.chooseTier(mctx, client, tiers, row.RecipientID, rowAmount, limit, row.IsRecurring)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to get tiers of %s", row.RecipientID)
 			}
+		}
 
-			err := client.Query(ctx, &q, vars)
+		// An earlier attempt which was interrupted or failed may still
+		// have created the sponsorship, so check before creating another.
+		var sponsored bool
+		if err == nil && row.UnconfirmedTs > 0 {
+			var s tier
+			s, sponsored, err = getSponsorship(mctx, client, row.SponsorID, row.RecipientID, since)
 			if err != nil {
-				logger.WithError(err).Error("failed to get sponsor id")
-				failed = true
-			} else {
-				sid = q.RepositoryOwner.ID
-				sponsorIds[row.SponsorID] = sid
+				logger.WithError(err).Errorf("failed to check sponsorships of %s", row.SponsorID)
+			} else if sponsored {
+				logger.Infof("%s:%s already sponsored ($%d)", row.SponsorID, row.RecipientID, s.MonthlyPriceInDollars)
+				rowAmount = s.MonthlyPriceInDollars
+				t = tier{}
+				if !s.IsCustomAmount {
+					t = s
+				}
 			}
 		}
 
-		if !failed {
+		// Lookups which failed because the run stopped aren't recorded.
+		if donateCtx.Err() != nil {
+			return errors.Wrap(context.Cause(donateCtx), "stopped donating")
+		}
+
+		if err == nil && !sponsored {
+			/* autoquery name: UpdateDonationUnconfirmedTs :exec
+
+			UPDATE donations
+			SET unconfirmed_ts = UNIXEPOCH()
+			WHERE id = ?;
+			*/
+			err = db.UpdateDonationUnconfirmedTs(ctx, row.ID)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to mark donation to %s unconfirmed", row.RecipientID)
+				continue
+			}
+
 			var m struct {
 				CreateSponsorship struct {
 					ClientMutationID string
@@ -107,41 +267,294 @@ func (c *CmdDonate) Run(
 			id := githubv4.String(fmt.Sprintf("%s:%s", row.SponsorID, row.RecipientID))
 			sponsorId := githubv4.ID(sid)
 			sponsorableLogin := githubv4.String(row.RecipientID)
-			var input githubv4.Input = githubv4.CreateSponsorshipInput{
+			isRecurring := githubv4.Boolean(row.IsRecurring)
+			privacyLevel := githubv4.SponsorshipPrivacy(row.PrivacyLevel)
+			receiveEmails := githubv4.Boolean(row.ReceiveEmails)
+			input := githubv4.CreateSponsorshipInput{
 				ClientMutationID: &id,
 				IsRecurring:      &isRecurring,
-				Amount:           &amount,
 				SponsorID:        &sponsorId,
 				SponsorableLogin: &sponsorableLogin,
 				PrivacyLevel:     &privacyLevel,
 				ReceiveEmails:    &receiveEmails,
 			}
+			if t.ID != "" {
+				rowAmount = t.MonthlyPriceInDollars
+				input.TierID = githubv4.NewID(t.ID)
+			} else {
+				input.Amount = githubv4.NewInt(githubv4.Int(rowAmount))
+			}
 
-			err := client.Mutate(ctx, &m, input, nil)
+			err = client.Mutate(mctx, &m, input, nil)
 			if err != nil {
 				logger.WithError(err).Errorf("failed to create sponsorship for %s", row.RecipientID)
-				failed = true
 			}
 		}
+		if err == nil {
+			budget.spend(row.SponsorID, rowAmount)
+		}
 
-		if failed {
-			/* autoquery name: UpdateDonationDonateAttemptTs :exec
+		err = c.record(ctx, db, row, rowAmount, t, err, isPermanent(err, gqlErrs.Errors()))
+		if err != nil {
+			logger.WithError(err).Errorf("failed to record donation to %s", row.RecipientID)
+		}
+	}
+
+	return nil
+}
+
+// record appends the outcome of the donation attempt for row to the
+// donation_events ledger and updates the donations table in a single
+// transaction. t is the tier sponsored, if any, donateErr the error the
+// attempt failed with, if any, and permanent whether it shouldn't be
+// retried.
+func (c *CmdDonate) record(
+	ctx context.Context,
+	db *database.DB,
+	row database.GetDonablesRow,
+	amount int,
+	t tier,
+	donateErr error,
+	permanent bool,
+) error {
+	return db.Tx(ctx, func(q *database.Queries) error {
+		event := database.InsertDonationEventParams{
+			SponsorID:    row.SponsorID,
+			RecipientID:  row.RecipientID,
+			Amount:       int64(amount),
+			IsRecurring:  row.IsRecurring,
+			PrivacyLevel: row.PrivacyLevel,
+			TierID:       t.ID,
+			TierName:     t.Name,
+			Outcome:      "success",
+		}
+		if donateErr != nil {
+			event.Outcome = "transient"
+			if permanent {
+				event.Outcome = "permanent"
+			}
+			event.Error = donateErr.Error()
+		}
+
+		/* autoquery name: InsertDonationEvent :exec
+
+		INSERT INTO donation_events (ts, sponsor_id, recipient_id, amount, is_recurring, privacy_level, tier_id, tier_name, outcome, error)
+		VALUES (UNIXEPOCH(), ?, ?, ?, ?, ?, ?, ?, ?, ?);
+		*/
+		err := q.InsertDonationEvent(ctx, event)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert donation event")
+		}
+
+		if donateErr != nil && permanent {
+			/* autoquery name: UpdateDonationFailed :exec
 
 			UPDATE donations
-			SET donate_attempt_ts = UNIXEPOCH()
+			SET
+				donate_attempt_ts = UNIXEPOCH(),
+				attempts = attempts + 1,
+				failed_ts = UNIXEPOCH(),
+				failure = ?,
+				unconfirmed_ts = 0
 			WHERE id = ?;
 			*/
-			_ = db.UpdateDonationDonateAttemptTs(ctx, row.ID)
-		} else {
-			/* autoquery name: UpdateDonationDonateTs :exec
+			err = q.UpdateDonationFailed(ctx, database.UpdateDonationFailedParams{
+				Failure: donateErr.Error(),
+				ID:      row.ID,
+			})
+			return errors.Wrap(err, "failed to mark donation failed")
+		}
+
+		if donateErr != nil {
+			/* autoquery name: UpdateDonationDonateAttemptTs :exec
 
 			UPDATE donations
-			SET donate_ts = UNIXEPOCH()
+			SET donate_attempt_ts = UNIXEPOCH(), attempts = attempts + 1
 			WHERE id = ?;
 			*/
-			_ = db.UpdateDonationDonateTs(ctx, row.ID)
+			err = q.UpdateDonationDonateAttemptTs(ctx, row.ID)
+			return errors.Wrap(err, "failed to update donate attempt ts")
+		}
+
+		/* autoquery name: UpdateDonationDonateTs :exec
+
+		UPDATE donations
+		SET donate_ts = UNIXEPOCH(), amount = ?, attempts = 0, unconfirmed_ts = 0
+		WHERE id = ?;
+		*/
+		err = q.UpdateDonationDonateTs(ctx, database.UpdateDonationDonateTsParams{
+			Amount: int64(amount),
+			ID:     row.ID,
+		})
+		return errors.Wrap(err, "failed to update donate ts")
+	})
+}
+
+// dryRun resolves every donable row into a plan entry and prints it. No
+// sponsorships are created and the donations table is left untouched.
+func (c *CmdDonate) dryRun(
+	ctx context.Context,
+	db *database.DB,
+	client *githubv4.Client,
+	rows []database.GetDonablesRow,
+	amounts map[int64]int,
+	budget *budgets,
+) error {
+	logger := log.FromContext(ctx)
+
+	sponsorIds := map[string]string{}
+	listings := map[string]bool{}
+	tiers := map[string][]tier{}
+
+	var err error
+	entries := make([]planEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := planEntry{
+			SponsorLogin:  row.SponsorID,
+			Recipient:     row.RecipientID,
+			Amount:        amounts[row.ID],
+			IsRecurring:   row.IsRecurring,
+			PrivacyLevel:  githubv4.SponsorshipPrivacy(row.PrivacyLevel),
+			ReceiveEmails: row.ReceiveEmails,
+		}
+
+		/* autoquery name: GetDonationDependentRepos :many
+
+		SELECT DISTINCT repo_name
+		FROM donation_dependents
+		WHERE sponsor_id = ? AND recipient_id = ?
+		ORDER BY repo_name;
+		*/
+		entry.Dependents, err = db.GetDonationDependentRepos(ctx, database.GetDonationDependentReposParams{
+			SponsorID:   row.SponsorID,
+			RecipientID: row.RecipientID,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to get dependent repos")
+		}
+
+		if _, ok := amounts[row.ID]; !ok {
+			entry.Status = "over budget"
+			entries = append(entries, entry)
+			continue
+		}
+
+		limit := budget.claim(row, entry.Amount)
+
+		sid, err := getSponsorID(ctx, client, sponsorIds, row.SponsorID)
+		if err != nil {
+			logger.WithError(err).Errorf("failed to get sponsor id for %s", row.SponsorID)
+			entry.Status = "unknown sponsor"
+			entries = append(entries, entry)
+			continue
+		}
+		entry.SponsorID = sid
+
+		hasListing, ok := listings[row.RecipientID]
+		if !ok {
+			hasListing, err = getHasSponsorsListing(ctx, client, row.RecipientID)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to get sponsors listing for %s", row.RecipientID)
+				entry.Status = "lookup failed"
+				entries = append(entries, entry)
+				continue
+			}
+			listings[row.RecipientID] = hasListing
+		}
+
+		if hasListing && c.Tiers != "custom" {
+			t, err := c.chooseTier(ctx, client, tiers, row.RecipientID, entry.Amount, limit, row.IsRecurring)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to get tiers of %s", row.RecipientID)
+				entry.Status = "lookup failed"
+				entries = append(entries, entry)
+				continue
+			}
+			if t.ID != "" {
+				entry.Amount = t.MonthlyPriceInDollars
+				entry.Tier = t.Name
+			}
+		}
+
+		if hasListing {
+			entry.Status = "ok"
+			budget.spend(row.SponsorID, entry.Amount)
+		} else {
+			entry.Status = "no sponsors listing"
+		}
+		entries = append(entries, entry)
+	}
+
+	err = writePlan(os.Stdout, entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to print plan")
+	}
+
+	if c.PlanPath != "" {
+		err = writePlanCsv(c.PlanPath, entries)
+		if err != nil {
+			return errors.Wrap(err, "failed to write plan")
 		}
+		logger.Infof("plan written to %s", c.PlanPath)
 	}
 
 	return nil
 }
+
+// getSponsorID returns the GraphQL node id of the sponsor login, caching
+// the result in ids.
+func getSponsorID(
+	ctx context.Context,
+	client *githubv4.Client,
+	ids map[string]string,
+	login string,
+) (string, error) {
+	if sid, ok := ids[login]; ok {
+		return sid, nil
+	}
+
+	var q struct {
+		RepositoryOwner struct {
+			ID string
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+	var vars map[string]any = map[string]any{
+		"login": githubv4.String(login),
+	}
+
+	err := client.Query(ctx, &q, vars)
+	if err != nil {
+		return "", err
+	}
+	if q.RepositoryOwner.ID == "" {
+		return "", errors.Wrap(errNotFound, login)
+	}
+
+	ids[login] = q.RepositoryOwner.ID
+	return q.RepositoryOwner.ID, nil
+}
+
+// getHasSponsorsListing reports whether login currently has an active
+// GitHub sponsors listing.
+func getHasSponsorsListing(
+	ctx context.Context,
+	client *githubv4.Client,
+	login string,
+) (bool, error) {
+	var q struct {
+		RepositoryOwner struct {
+			Sponsorable struct {
+				HasSponsorsListing bool
+			} `graphql:"... on Sponsorable"`
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+	var vars map[string]any = map[string]any{
+		"login": githubv4.String(login),
+	}
+
+	err := client.Query(ctx, &q, vars)
+	if err != nil {
+		return false, err
+	}
+	return q.RepositoryOwner.Sponsorable.HasSponsorsListing, nil
+}
@@ -0,0 +1,36 @@
+package donate
+
+import (
+	"strings"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/utils/httpgh"
+)
+
+// errNotFound is returned when a sponsor login doesn't exist.
+var errNotFound = errors.New("not found")
+
+// permanentTypes are the GraphQL error types which retrying won't fix, eg.
+// the recipient has no sponsors listing or the tier doesn't exist.
+var permanentTypes = map[string]bool{
+	"NOT_FOUND":     true,
+	"UNPROCESSABLE": true,
+}
+
+// isPermanent reports whether a donation which failed with err and the
+// GraphQL errors gqlErrs should not be retried. Network errors, rate
+// limits, server errors and insufficient token scopes are transient.
+func isPermanent(err error, gqlErrs []httpgh.GraphQLError) bool {
+	if errors.Is(err, errNotFound) {
+		return true
+	}
+	for _, e := range gqlErrs {
+		if permanentTypes[e.Type] {
+			return true
+		}
+		if e.Type == "" && strings.HasPrefix(e.Message, "Could not resolve to") {
+			return true
+		}
+	}
+	return false
+}
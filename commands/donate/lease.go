@@ -0,0 +1,90 @@
+package donate
+
+import (
+	"context"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/google/uuid"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+)
+
+// donateLease is the name of the lease a donate run holds while it creates
+// sponsorships.
+const donateLease = "donate"
+
+// acquireLease takes the donate lease for timeout and keeps renewing it
+// until the returned release func is called. It fails if another run
+// holds an unexpired lease. The returned context is cancelled if a renewal
+// fails or the lease is taken over, as another run may then be donating.
+func acquireLease(ctx context.Context, db *database.DB, timeout time.Duration) (lctx context.Context, release func(), err error) {
+	holder := uuid.NewString()
+
+	/* autoquery name: AcquireLease :execrows
+
+	INSERT INTO leases (name, holder, expires_ts)
+	VALUES (?, ?, UNIXEPOCH() + CAST(sqlc.arg(timeout) AS INTEGER))
+	ON CONFLICT (name)
+	DO UPDATE SET
+		holder = excluded.holder,
+		expires_ts = excluded.expires_ts
+	WHERE leases.expires_ts < UNIXEPOCH() OR leases.holder = excluded.holder;
+	*/
+	params := database.AcquireLeaseParams{
+		Name:    donateLease,
+		Holder:  holder,
+		Timeout: int64(timeout.Seconds()),
+	}
+	acquired, err := db.AcquireLease(ctx, params)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to acquire donate lease")
+	}
+	if acquired == 0 {
+		return nil, nil, errors.New("another donate run is in progress")
+	}
+
+	lctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(timeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lctx.Done():
+				return
+			case <-ticker.C:
+			}
+			renewed, err := db.AcquireLease(lctx, params)
+			if err == nil && renewed == 0 {
+				err = errors.New("lease was taken over")
+			}
+			if err != nil {
+				if lctx.Err() == nil {
+					log.FromContext(ctx).WithError(err).Error("lost the donate lease")
+					cancel(errors.Wrap(err, "lost the donate lease"))
+				}
+				return
+			}
+		}
+	}()
+
+	return lctx, func() {
+		cancel(nil)
+		<-done
+
+		/* autoquery name: ReleaseLease :exec
+
+		DELETE FROM leases
+		WHERE name = ? AND holder = ?;
+		*/
+		err := db.ReleaseLease(context.Background(), database.ReleaseLeaseParams{
+			Name:   donateLease,
+			Holder: holder,
+		})
+		if err != nil {
+			log.FromContext(ctx).WithError(err).Error("failed to release donate lease")
+		}
+	}, nil
+}
@@ -0,0 +1,76 @@
+package donate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/thnxdev/utils/database"
+)
+
+func TestLeaseContention(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	_, release, err := acquireLease(ctx, db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = acquireLease(ctx, db, time.Minute)
+	if err == nil {
+		t.Fatal("acquired a held lease")
+	}
+
+	release()
+	_, release, err = acquireLease(ctx, db, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to acquire a released lease: %v", err)
+	}
+	defer release()
+
+	// The lease of a run which stopped renewing it expires.
+	_, err = conn.Exec(`UPDATE leases SET expires_ts = UNIXEPOCH() - 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, release2, err := acquireLease(ctx, db, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to take over an expired lease: %v", err)
+	}
+	release2()
+}
+
+func TestLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	lctx, release, err := acquireLease(ctx, db, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Exec(`UPDATE leases SET holder = 'other'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context wasn't cancelled when the lease was taken over")
+	}
+	if context.Cause(lctx) == context.Canceled {
+		t.Errorf("cause = %v, want the lost lease", context.Cause(lctx))
+	}
+
+	// Releasing a lost lease leaves the new holder's alone.
+	release()
+	var holder string
+	err = conn.QueryRow(`SELECT holder FROM leases`).Scan(&holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder != "other" {
+		t.Errorf("holder = %q, want other", holder)
+	}
+}
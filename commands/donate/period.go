@@ -0,0 +1,16 @@
+package donate
+
+import "time"
+
+// PeriodStart returns when the --period ("month", "week" or "day") which t
+// is in started, in t's location. Weeks start on Monday.
+func PeriodStart(t time.Time, period string) time.Time {
+	y, m, d := t.Date()
+	switch period {
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
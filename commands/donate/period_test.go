@@ -0,0 +1,31 @@
+package donate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DST started on 2026-03-08, the month and week started in EST.
+	tests := []struct {
+		t      time.Time
+		period string
+		want   time.Time
+	}{
+		{time.Date(2026, 3, 9, 10, 0, 0, 0, ny), "month", time.Date(2026, 3, 1, 5, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 9, 10, 0, 0, 0, ny), "week", time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 12, 0, 0, 0, ny), "week", time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 12, 0, 0, 0, ny), "day", time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC).In(ny), "month", time.Date(2025, 12, 1, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got := PeriodStart(test.t, test.period)
+		if !got.Equal(test.want) {
+			t.Errorf("PeriodStart(%s, %s) = %s, want %s", test.t, test.period, got.UTC(), test.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package donate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// planEntry is a single sponsorship that would be created by donate.
+type planEntry struct {
+	SponsorLogin  string
+	SponsorID     string
+	Recipient     string
+	Amount        int
+	Tier          string
+	IsRecurring   bool
+	PrivacyLevel  githubv4.SponsorshipPrivacy
+	ReceiveEmails bool
+	Dependents    []string
+	Status        string
+}
+
+var planHeader = []string{"sponsor", "sponsor_id", "recipient", "amount", "tier", "recurring", "privacy", "receive_emails", "dependents", "status"}
+
+func (e planEntry) record() []string {
+	return []string{
+		e.SponsorLogin,
+		e.SponsorID,
+		e.Recipient,
+		strconv.Itoa(e.Amount),
+		e.Tier,
+		strconv.FormatBool(e.IsRecurring),
+		string(e.PrivacyLevel),
+		strconv.FormatBool(e.ReceiveEmails),
+		strings.Join(e.Dependents, ","),
+		e.Status,
+	}
+}
+
+// writePlan prints the plan as an aligned table followed by a total per
+// sponsor.
+func writePlan(w io.Writer, entries []planEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPONSOR\tSPONSOR ID\tRECIPIENT\tAMOUNT\tTIER\tRECURRING\tPRIVACY\tEMAILS\tDEPENDENTS\tSTATUS")
+
+	totals := map[string]int{}
+	sponsors := []string{}
+	for _, e := range entries {
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%s\t%t\t%s\t%t\t%d\t%s\n",
+			e.SponsorLogin,
+			e.SponsorID,
+			e.Recipient,
+			e.Amount,
+			e.Tier,
+			e.IsRecurring,
+			e.PrivacyLevel,
+			e.ReceiveEmails,
+			len(e.Dependents),
+			e.Status,
+		)
+		if _, ok := totals[e.SponsorLogin]; !ok {
+			sponsors = append(sponsors, e.SponsorLogin)
+			totals[e.SponsorLogin] = 0
+		}
+		if e.Status == "ok" {
+			totals[e.SponsorLogin] += e.Amount
+		}
+	}
+
+	if len(sponsors) > 0 {
+		fmt.Fprintln(tw, "\t\t\t\t\t\t\t")
+	}
+	for _, s := range sponsors {
+		fmt.Fprintf(tw, "%s\t\tTOTAL\t%d\t\t\t\t\t\t\n", s, totals[s])
+	}
+
+	return tw.Flush()
+}
+
+// writePlanCsv writes the plan to a csv file at path.
+func writePlanCsv(path string, entries []planEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write(planHeader)
+	for _, e := range entries {
+		_ = w.Write(e.record())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,67 @@
+package donate
+
+import (
+	"context"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+// applyPolicy returns the rows whose recipient the policy allows. The
+// account type of each recipient is only looked up if a rule needs it,
+// recipients whose type can't be resolved are skipped until the next run.
+func applyPolicy(
+	ctx context.Context,
+	client *githubv4.Client,
+	pol *policy.Policy,
+	rows []database.GetDonablesRow,
+) []database.GetDonablesRow {
+	logger := log.FromContext(ctx)
+
+	types := map[string]string{}
+	allowed := make([]database.GetDonablesRow, 0, len(rows))
+	for _, row := range rows {
+		typ, ok := types[row.RecipientID]
+		if !ok && pol.NeedsType() {
+			var err error
+			typ, err = getOwnerType(ctx, client, row.RecipientID)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to get account type of %s", row.RecipientID)
+				continue
+			}
+			types[row.RecipientID] = typ
+		}
+
+		if ok, reason := pol.Check(row.RecipientID, typ); !ok {
+			logger.Infof("skipping %s:%s: %s", row.SponsorID, row.RecipientID, reason)
+			continue
+		}
+		allowed = append(allowed, row)
+	}
+	return allowed
+}
+
+// getOwnerType returns the account type of login, ie. User or
+// Organization.
+func getOwnerType(
+	ctx context.Context,
+	client *githubv4.Client,
+	login string,
+) (string, error) {
+	var q struct {
+		RepositoryOwner struct {
+			Typename string `graphql:"__typename"`
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+	var vars map[string]any = map[string]any{
+		"login": githubv4.String(login),
+	}
+
+	err := client.Query(ctx, &q, vars)
+	if err != nil {
+		return "", err
+	}
+	return q.RepositoryOwner.Typename, nil
+}
@@ -0,0 +1,70 @@
+package donate
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// getSponsorship returns the tier of sponsor's active sponsorship of
+// recipient, if it has one which covers a donation: a recurring one, or a
+// one-time one created since since.
+func getSponsorship(
+	ctx context.Context,
+	client *githubv4.Client,
+	sponsor string,
+	recipient string,
+	since time.Time,
+) (tier, bool, error) {
+	var cursor *githubv4.String
+	for {
+		var q struct {
+			RepositoryOwner struct {
+				Sponsorable struct {
+					SponsorshipsAsSponsor struct {
+						Nodes []struct {
+							CreatedAt        githubv4.DateTime
+							IsOneTimePayment bool
+							Tier             tier
+							Sponsorable      struct {
+								RepositoryOwner struct {
+									Login string
+								} `graphql:"... on RepositoryOwner"`
+							}
+						}
+						PageInfo struct {
+							EndCursor   string
+							HasNextPage bool
+						}
+					} `graphql:"sponsorshipsAsSponsor(first: 100, after: $cursor, activeOnly: true)"`
+				} `graphql:"... on Sponsorable"`
+			} `graphql:"repositoryOwner(login: $login)"`
+		}
+		var vars map[string]any = map[string]any{
+			"login":  githubv4.String(sponsor),
+			"cursor": cursor,
+		}
+
+		err := client.Query(ctx, &q, vars)
+		if err != nil {
+			return tier{}, false, err
+		}
+
+		sponsorships := q.RepositoryOwner.Sponsorable.SponsorshipsAsSponsor
+		for _, s := range sponsorships.Nodes {
+			if !strings.EqualFold(s.Sponsorable.RepositoryOwner.Login, recipient) {
+				continue
+			}
+			if !s.IsOneTimePayment || !s.CreatedAt.Before(since) {
+				return s.Tier, true, nil
+			}
+		}
+
+		if !sponsorships.PageInfo.HasNextPage {
+			return tier{}, false, nil
+		}
+		cursor = githubv4.NewString(githubv4.String(sponsorships.PageInfo.EndCursor))
+	}
+}
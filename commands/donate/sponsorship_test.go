@@ -0,0 +1,82 @@
+package donate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+func TestGetSponsorship(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	node := func(login string, oneTime bool, created time.Time, price int) map[string]any {
+		return map[string]any{
+			"createdAt":        created,
+			"isOneTimePayment": oneTime,
+			"tier":             map[string]any{"id": "T_" + login, "monthlyPriceInDollars": price},
+			"sponsorable":      map[string]any{"login": login},
+		}
+	}
+	pages := [][]map[string]any{
+		{
+			node("alice", false, since.AddDate(-1, 0, 0), 5),
+			node("bob", true, since.Add(-time.Hour), 7),
+		},
+		{
+			node("Carol", true, since.Add(time.Hour), 3),
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Cursor *string
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page := 0
+		if req.Variables.Cursor != nil {
+			page = 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"repositoryOwner": map[string]any{
+					"sponsorshipsAsSponsor": map[string]any{
+						"nodes":    pages[page],
+						"pageInfo": map[string]any{"endCursor": "c1", "hasNextPage": page == 0},
+					},
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+	client := githubv4.NewEnterpriseClient(srv.URL, srv.Client())
+
+	tests := []struct {
+		recipient string
+		want      int
+	}{
+		{"alice", 5}, // recurring
+		{"bob", 0},   // one-time before since
+		{"carol", 3}, // one-time since since, on the second page
+		{"dave", 0},
+	}
+	for _, test := range tests {
+		got, ok, err := getSponsorship(context.Background(), client, "acme", test.recipient, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (test.want > 0) || got.MonthlyPriceInDollars != test.want {
+			t.Errorf("getSponsorship(%s) = $%d, %v, want $%d", test.recipient, got.MonthlyPriceInDollars, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package donate
+
+import (
+	"context"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/thnxdev/utils/utils/log"
+)
+
+// tier is a GitHub Sponsors tier of a recipient's listing.
+type tier struct {
+	ID                    string
+	Name                  string
+	MonthlyPriceInDollars int
+	IsOneTime             bool
+	IsCustomAmount        bool
+}
+
+// chooseTier returns the recipient's tier to sponsor amount with according
+// to --tiers, costing at most limit unless it's -1, caching the
+// recipient's tiers in cache. The zero tier is returned if none matches and
+// the custom amount should be used instead.
+func (c *CmdDonate) chooseTier(
+	ctx context.Context,
+	client *githubv4.Client,
+	cache map[string][]tier,
+	login string,
+	amount int,
+	limit int,
+	isRecurring bool,
+) (tier, error) {
+	tiers, ok := cache[login]
+	if !ok {
+		var err error
+		tiers, err = getTiers(ctx, client, login)
+		if err != nil {
+			return tier{}, err
+		}
+		cache[login] = tiers
+	}
+
+	t, ok := c.pickTier(tiers, amount, limit, isRecurring)
+	if !ok {
+		log.FromContext(ctx).Infof("no matching tier for %s, using a custom amount", login)
+		return tier{}, nil
+	}
+	log.FromContext(ctx).Infof("using tier %q ($%d) for %s", t.Name, t.MonthlyPriceInDollars, login)
+	return t, nil
+}
+
+// pickTier picks the tier for amount from tiers according to --tiers.
+// Custom amount tiers, tiers of the other kind (one-time vs recurring) and
+// tiers costing more than limit, unless it's -1, are never picked. Ties go
+// to the cheaper tier.
+func (c *CmdDonate) pickTier(tiers []tier, amount, limit int, isRecurring bool) (tier, bool) {
+	var best tier
+	found := false
+	for _, t := range tiers {
+		if t.IsCustomAmount || t.IsOneTime == isRecurring {
+			continue
+		}
+		if limit >= 0 && t.MonthlyPriceInDollars > limit {
+			continue
+		}
+		switch c.Tiers {
+		case "closest":
+			d, bestD := distance(t, amount), distance(best, amount)
+			if !found || d < bestD || (d == bestD && t.MonthlyPriceInDollars < best.MonthlyPriceInDollars) {
+				best, found = t, true
+			}
+		case "at-least":
+			if t.MonthlyPriceInDollars >= amount && (!found || t.MonthlyPriceInDollars < best.MonthlyPriceInDollars) {
+				best, found = t, true
+			}
+		}
+	}
+	return best, found
+}
+
+func distance(t tier, amount int) int {
+	d := t.MonthlyPriceInDollars - amount
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// getTiers returns the tiers of login's sponsors listing.
+func getTiers(
+	ctx context.Context,
+	client *githubv4.Client,
+	login string,
+) ([]tier, error) {
+	var q struct {
+		RepositoryOwner struct {
+			Sponsorable struct {
+				SponsorsListing struct {
+					Tiers struct {
+						Nodes []tier
+					} `graphql:"tiers(first: 100)"`
+				}
+			} `graphql:"... on Sponsorable"`
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+	var vars map[string]any = map[string]any{
+		"login": githubv4.String(login),
+	}
+
+	err := client.Query(ctx, &q, vars)
+	if err != nil {
+		return nil, err
+	}
+	return q.RepositoryOwner.Sponsorable.SponsorsListing.Tiers.Nodes, nil
+}
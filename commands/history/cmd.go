@@ -0,0 +1,86 @@
+//go:generate autoquery
+package history
+
+//
+// History prints the donation_events ledger written by donate, optionally
+// filtered by month (UTC), sponsor and recipient. Every attempt is listed
+// along with the error of failed ones; the total only counts successful
+// donations.
+//
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/database"
+)
+
+type CmdHistory struct {
+	Month     string `help:"Only show donations made in this month (YYYY-MM)." placeholder:"YYYY-MM"`
+	Sponsor   string `help:"Only show donations made by this sponsor."`
+	Recipient string `help:"Only show donations made to this recipient."`
+}
+
+func (c *CmdHistory) Run(
+	ctx context.Context,
+	db *database.DB,
+) error {
+	if c.Month != "" {
+		_, err := time.Parse("2006-01", c.Month)
+		if err != nil {
+			return errors.Errorf("invalid month %q, expected YYYY-MM", c.Month)
+		}
+	}
+
+	/* autoquery name: GetDonationEvents :many
+
+	SELECT e.ts, e.sponsor_id, e.recipient_id, e.amount, e.tier_name, e.is_recurring, e.privacy_level, e.outcome, e.error
+	FROM donation_events e, (
+		SELECT
+			CAST(sqlc.arg(month) AS TEXT) AS month,
+			CAST(sqlc.arg(sponsor_id) AS TEXT) AS sponsor_id,
+			CAST(sqlc.arg(recipient_id) AS TEXT) AS recipient_id
+	) f
+	WHERE
+		(f.month = '' OR STRFTIME('%Y-%m', e.ts, 'unixepoch') = f.month) AND
+		(f.sponsor_id = '' OR e.sponsor_id = f.sponsor_id) AND
+		(f.recipient_id = '' OR e.recipient_id = f.recipient_id)
+	ORDER BY e.ts, e.id;
+	*/
+	events, err := db.GetDonationEvents(ctx, database.GetDonationEventsParams{
+		Month:       c.Month,
+		SponsorID:   c.Sponsor,
+		RecipientID: c.Recipient,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get donation events")
+	}
+
+	total := int64(0)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSPONSOR\tRECIPIENT\tAMOUNT\tTIER\tRECURRING\tPRIVACY\tOUTCOME\tERROR")
+	for _, e := range events {
+		if e.Outcome == "success" {
+			total += e.Amount
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%s\t%t\t%s\t%s\t%s\n",
+			time.Unix(e.Ts, 0).UTC().Format(time.DateTime),
+			e.SponsorID,
+			e.RecipientID,
+			e.Amount,
+			e.TierName,
+			e.IsRecurring,
+			e.PrivacyLevel,
+			e.Outcome,
+			e.Error,
+		)
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t\t%d\t\t\t\t\t\n", total)
+	return tw.Flush()
+}
@@ -0,0 +1,145 @@
+//go:generate autoquery
+package importdonations
+
+//
+// Import donations from a csv, json, yaml or ndjson file. The format is
+// taken from --format, or detected from the file's extension and first
+// character. A csv file's first line is the header, json and yaml files
+// hold a list of objects and ndjson files one object per line.
+//
+// Each field of a donation is read from the column (or object key) with
+// its default name, or the one given with --columns:
+//	- recipient: the GitHub login to sponsor, or the first csv column;
+//	- sponsor: the sponsoring entity, --entity by default;
+//	- amount: a fixed amount in USD, overrides donate's --amount/budget;
+//	- recurring: true or false, overrides donate's --is-recurring;
+//	- privacy (privacy_level): public or private;
+//	- receive_emails: true or false.
+// Empty values leave the setting to the sponsor's defaults or donate's
+// flags. Every row is validated, invalid rows are reported and skipped,
+// or with --strict abort the import. All rows are inserted in a single
+// transaction.
+//
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+type CmdImport struct {
+	FilePath string            `help:"The file to import from." type:"path" required:""`
+	Format   string            `help:"The format of the file (${enum})." enum:"auto,csv,json,yaml,ndjson" default:"auto"`
+	Entity   utils.Entity      `help:"The GitHub entity to import into, for rows without a sponsor."`
+	Columns  map[string]string `help:"Map fields (recipient, sponsor, amount, recurring, privacy, receive_emails) to columns or keys, eg. recipient=login."`
+	Strict   bool              `help:"Import nothing if any row is invalid."`
+}
+
+func (c *CmdImport) Run(
+	ctx context.Context,
+	db *database.DB,
+	pol *policy.Policy,
+) error {
+	log.FromContext(ctx).Infof("opening %s for import", c.FilePath)
+
+	b, err := os.ReadFile(c.FilePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", c.FilePath)
+	}
+
+	format := c.Format
+	if format == "auto" {
+		format = detectFormat(c.FilePath, b)
+		log.FromContext(ctx).Debugf("detected %s format", format)
+	}
+
+	rows, header, err := readRows(format, b)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", c.FilePath)
+	}
+	if len(rows) == 0 {
+		return errors.New("file is empty")
+	}
+
+	keys, err := c.keys(format, header)
+	if err != nil {
+		return err
+	}
+
+	rep := report{}
+	records := make([]record, 0, len(rows))
+	for _, row := range rows {
+		values := map[string]string{}
+		for field, key := range keys {
+			values[field] = row.Values[key]
+		}
+
+		err := row.check(keys)
+		if err != nil {
+			rep.Invalid = append(rep.Invalid, invalid{Pos: row.Pos, Recipient: values["recipient"], Err: err})
+			continue
+		}
+		r, err := parseRecord(row.Pos, values, string(c.Entity))
+		if err != nil {
+			rep.Invalid = append(rep.Invalid, invalid{Pos: row.Pos, Recipient: values["recipient"], Err: err})
+			continue
+		}
+		records = append(records, r)
+	}
+
+	if c.Strict && len(rep.Invalid) > 0 {
+		_ = rep.write(os.Stdout)
+		return errors.Errorf("%d invalid rows, nothing was imported", len(rep.Invalid))
+	}
+
+	err = insert(ctx, db, pol, records, &rep)
+	if err != nil {
+		return err
+	}
+	return rep.write(os.Stdout)
+}
+
+// keys returns the column or object key each field is read from. Fields
+// which aren't in the header are left out, except for the recipient which
+// defaults to the first column of a csv file.
+func (c *CmdImport) keys(format string, header []string) (map[string]string, error) {
+	for field := range c.Columns {
+		if _, ok := fields[field]; !ok {
+			names := make([]string, 0, len(fields))
+			for f := range fields {
+				names = append(names, f)
+			}
+			sort.Strings(names)
+			return nil, errors.Errorf("unknown field %q in --columns, expected one of %s", field, strings.Join(names, ", "))
+		}
+	}
+
+	inHeader := map[string]bool{}
+	for _, name := range header {
+		inHeader[name] = true
+	}
+
+	keys := map[string]string{}
+	for field, name := range fields {
+		mapped, ok := c.Columns[field]
+		if ok {
+			name = strings.ToLower(mapped)
+		}
+		switch {
+		case inHeader[name]:
+			keys[field] = name
+		case ok:
+			return nil, errors.Errorf("column %q for %s not found", mapped, field)
+		case field == "recipient" && format == "csv" && len(header) > 0:
+			keys[field] = header[0]
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,202 @@
+package importdonations
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// row is a donation read from a file before validation. Values are keyed
+// by their lower cased column header or object key.
+type row struct {
+	// Pos is the line of the row in csv and ndjson files and the index of
+	// the item (from 1) in json and yaml files.
+	Pos    int
+	Values map[string]string
+	// Errs holds why the values of json and yaml keys which aren't
+	// scalars couldn't be read, by key.
+	Errs map[string]error
+}
+
+// check returns the error of the first field, by name, whose value
+// couldn't be read. keys are the keys the fields are read from.
+func (r row) check(keys map[string]string) error {
+	names := make([]string, 0, len(keys))
+	for field := range keys {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		if err := r.Errs[keys[field]]; err != nil {
+			return errors.Wrapf(err, "field %s", field)
+		}
+	}
+	return nil
+}
+
+// detectFormat returns the format of the file from its extension, or its
+// first character if the extension isn't known.
+func detectFormat(path string, b []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	switch b := bytes.TrimSpace(b); {
+	case bytes.HasPrefix(b, []byte("[")):
+		return "json"
+	case bytes.HasPrefix(b, []byte("{")):
+		return "ndjson"
+	case bytes.HasPrefix(b, []byte("-")):
+		return "yaml"
+	}
+	return "csv"
+}
+
+// readRows parses b in format. header lists the columns or keys found, in
+// order of appearance.
+func readRows(format string, b []byte) (rows []row, header []string, err error) {
+	switch format {
+	case "csv":
+		return readCSV(b)
+	case "json":
+		var items []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&items)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to decode json")
+		}
+		rows, header := fromObjects(items, nil)
+		return rows, header, nil
+	case "ndjson":
+		return readNDJSON(b)
+	case "yaml":
+		var items []map[string]any
+		err = yaml.Unmarshal(b, &items)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to decode yaml")
+		}
+		rows, header := fromObjects(items, nil)
+		return rows, header, nil
+	}
+	return nil, nil, errors.Errorf("unknown format %q", format)
+}
+
+func readCSV(b []byte) ([]row, []string, error) {
+	reader := csv.NewReader(bytes.NewReader(b))
+	reader.FieldsPerRecord = -1
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read csv")
+	}
+	if len(lines) == 0 {
+		return nil, nil, nil
+	}
+
+	// first line is header
+	header := make([]string, len(lines[0]))
+	for i, name := range lines[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	rows := make([]row, 0, len(lines)-1)
+	for i, line := range lines[1:] {
+		r := row{Pos: i + 2, Values: map[string]string{}}
+		for col, v := range line {
+			if col < len(header) {
+				r.Values[header[col]] = strings.TrimSpace(v)
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows, header, nil
+}
+
+func readNDJSON(b []byte) ([]row, []string, error) {
+	var items []map[string]any
+	var lines []int
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item map[string]any
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		err := dec.Decode(&item)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "line %d: failed to decode json", n)
+		}
+		items = append(items, item)
+		lines = append(lines, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read ndjson")
+	}
+	rows, header := fromObjects(items, lines)
+	return rows, header, nil
+}
+
+// fromObjects converts decoded json or yaml objects into rows. pos holds
+// the position of each item, its index from 1 if nil. Values which aren't
+// scalars are recorded in the row's Errs.
+func fromObjects(items []map[string]any, pos []int) ([]row, []string) {
+	var header []string
+	seen := map[string]bool{}
+	rows := make([]row, 0, len(items))
+	for i, item := range items {
+		r := row{Pos: i + 1, Values: map[string]string{}, Errs: map[string]error{}}
+		if pos != nil {
+			r.Pos = pos[i]
+		}
+		keys := make([]string, 0, len(item))
+		for k := range item {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			k := strings.ToLower(strings.TrimSpace(key))
+			s, err := scalar(item[key])
+			if err != nil {
+				r.Errs[k] = err
+			}
+			r.Values[k] = s
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows, header
+}
+
+// scalar formats a decoded json or yaml value as the string it would be in
+// a csv file.
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case json.Number, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", errors.Errorf("unsupported value %v", v)
+}
@@ -0,0 +1,208 @@
+package importdonations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+// The fields of a record and the column or key they are read from by
+// default.
+var fields = map[string]string{
+	"recipient":      "recipient",
+	"sponsor":        "sponsor",
+	"amount":         "amount",
+	"recurring":      "recurring",
+	"privacy":        "privacy_level",
+	"receive_emails": "receive_emails",
+}
+
+var loginRe = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+// record is a validated donation to import. Unset settings fall back to
+// the sponsor's defaults or the flags of donate.
+type record struct {
+	Pos           int
+	Recipient     string
+	Sponsor       string
+	Amount        sql.NullInt64
+	IsRecurring   sql.NullBool
+	PrivacyLevel  sql.NullString
+	ReceiveEmails sql.NullBool
+}
+
+// invalid is a row which failed validation.
+type invalid struct {
+	Pos       int
+	Recipient string
+	Err       error
+}
+
+// parseRecord validates the field values of the row at pos. sponsor is
+// used if the row has no sponsor.
+func parseRecord(pos int, values map[string]string, sponsor string) (record, error) {
+	r := record{Pos: pos, Recipient: values["recipient"], Sponsor: values["sponsor"]}
+	if r.Sponsor == "" {
+		r.Sponsor = sponsor
+	}
+
+	if r.Recipient == "" {
+		return r, errors.New("missing recipient")
+	}
+	if !loginRe.MatchString(r.Recipient) {
+		return r, errors.Errorf("invalid recipient %q", r.Recipient)
+	}
+	if r.Sponsor == "" {
+		return r, errors.New("missing sponsor, set a sponsor column or --entity")
+	}
+	if !loginRe.MatchString(r.Sponsor) {
+		return r, errors.Errorf("invalid sponsor %q", r.Sponsor)
+	}
+
+	if v := values["amount"]; v != "" {
+		amount, err := strconv.ParseInt(strings.TrimPrefix(v, "$"), 10, 64)
+		if err != nil || amount < 1 {
+			return r, errors.Errorf("invalid amount %q, expected a whole number of USD", v)
+		}
+		r.Amount = database.Int64(amount)
+	}
+
+	var err error
+	r.IsRecurring, err = parseBool("recurring", values["recurring"])
+	if err != nil {
+		return r, err
+	}
+	r.ReceiveEmails, err = parseBool("receive_emails", values["receive_emails"])
+	if err != nil {
+		return r, err
+	}
+
+	switch v := strings.ToUpper(values["privacy"]); v {
+	case "":
+	case "PUBLIC", "PRIVATE":
+		r.PrivacyLevel = database.String(v)
+	default:
+		return r, errors.Errorf("invalid privacy %q, expected public or private", values["privacy"])
+	}
+
+	return r, nil
+}
+
+func parseBool(name, s string) (sql.NullBool, error) {
+	if s == "" {
+		return sql.NullBool{}, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return sql.NullBool{}, errors.Errorf("invalid %s %q, expected true or false", name, s)
+	}
+	return database.Bool(v), nil
+}
+
+// report counts the outcome of an import.
+type report struct {
+	Inserted  int
+	Duplicate int
+	Skipped   int
+	Invalid   []invalid
+}
+
+func (r report) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(r.Invalid) > 0 {
+		fmt.Fprintln(tw, "ROW\tRECIPIENT\tERROR")
+		for _, i := range r.Invalid {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", i.Pos, i.Recipient, i.Err)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "inserted\t%d\n", r.Inserted)
+	fmt.Fprintf(tw, "duplicate\t%d\n", r.Duplicate)
+	fmt.Fprintf(tw, "skipped by policy\t%d\n", r.Skipped)
+	fmt.Fprintf(tw, "invalid\t%d\n", len(r.Invalid))
+	return tw.Flush()
+}
+
+// insert adds the records to the donations table in a single transaction.
+// Records which are already in the table are counted as duplicates and
+// have their settings updated.
+func insert(
+	ctx context.Context,
+	db *database.DB,
+	pol *policy.Policy,
+	records []record,
+	rep *report,
+) error {
+	logger := log.FromContext(ctx)
+	return db.Tx(ctx, func(q *database.Queries) error {
+		for _, r := range records {
+			if ok, reason := pol.Check(r.Recipient, ""); !ok {
+				logger.Infof("skipping %s: %s", r.Recipient, reason)
+				rep.Skipped++
+				continue
+			}
+
+			/* autoquery name: InsertImportedDonation :execrows
+
+			INSERT INTO donations (sponsor_id, recipient_id, last_ts, target_amount, is_recurring, privacy_level, receive_emails)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (sponsor_id, recipient_id)
+			DO NOTHING;
+			*/
+			n, err := q.InsertImportedDonation(ctx, database.InsertImportedDonationParams{
+				SponsorID:     r.Sponsor,
+				RecipientID:   r.Recipient,
+				LastTs:        time.Now().Unix(),
+				TargetAmount:  r.Amount,
+				IsRecurring:   r.IsRecurring,
+				PrivacyLevel:  r.PrivacyLevel,
+				ReceiveEmails: r.ReceiveEmails,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "row %d: failed to insert donation", r.Pos)
+			}
+			if n > 0 {
+				logger.Debugf("added %s:%s", r.Sponsor, r.Recipient)
+				rep.Inserted++
+				continue
+			}
+
+			/* autoquery name: UpdateImportedDonation :exec
+
+			UPDATE donations
+			SET
+				last_ts = CASE WHEN cancel_ts > 0 THEN UNIXEPOCH() ELSE last_ts END,
+				cancel_ts = 0,
+				target_amount = COALESCE(?, target_amount),
+				is_recurring = COALESCE(?, is_recurring),
+				privacy_level = COALESCE(?, privacy_level),
+				receive_emails = COALESCE(?, receive_emails)
+			WHERE sponsor_id = ? AND recipient_id = ?;
+			*/
+			err = q.UpdateImportedDonation(ctx, database.UpdateImportedDonationParams{
+				TargetAmount:  r.Amount,
+				IsRecurring:   r.IsRecurring,
+				PrivacyLevel:  r.PrivacyLevel,
+				ReceiveEmails: r.ReceiveEmails,
+				SponsorID:     r.Sponsor,
+				RecipientID:   r.Recipient,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "row %d: failed to update donation", r.Pos)
+			}
+			rep.Duplicate++
+		}
+		return nil
+	})
+}
@@ -0,0 +1,222 @@
+//go:generate autoquery
+package importsbom
+
+//
+// Import the dependencies an SBOM says a service ships. SPDX 2.x and
+// CycloneDX SBOMs in JSON are understood. Each component is mapped to a
+// GitHub repository from, in order:
+//	- a pkg:github package URL;
+//	- its VCS, download and website references, and the vcs_url,
+//	  repository_url and download_url qualifiers of its package URL;
+//	- the registry of its package URL's type (golang, npm, pypi, cargo
+//	  or gem).
+// Owners of those repos with a GitHub Sponsors listing are added to the
+// donations table if the recipient policy allows them. Every component
+// that led to a donation is recorded in sbom_components with the SBOM's
+// name, so it's clear which shipped component a recipient is funded for.
+//
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+
+	utils "github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+	"github.com/thnxdev/utils/utils/registry"
+)
+
+type CmdImportSbom struct {
+	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
+	FilePaths            []string            `arg:"" help:"The SPDX or CycloneDX SBOMs (JSON) to import." type:"existingfile"`
+	Entity               utils.Entity        `help:"The GitHub entity sponsoring the SBOMs' components." required:""`
+	Concurrency          int                 `help:"Number of components to resolve concurrently." default:"8"`
+}
+
+// Validate requires at least one component to be resolved at a time.
+func (c *CmdImportSbom) Validate() error {
+	if c.Concurrency < 1 {
+		return errors.Errorf("concurrency must be at least 1, got %d", c.Concurrency)
+	}
+	return nil
+}
+
+func (c *CmdImportSbom) Run(
+	ctx context.Context,
+	db *database.DB,
+	pol *policy.Policy,
+) error {
+	logger := log.FromContext(ctx)
+	logger.Info("starting")
+
+	resolver := registry.NewResolver(&http.Client{Timeout: time.Minute})
+
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	)
+	client := githubv4.NewClient(
+		oauth2.NewClient(
+			hctx,
+			oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: string(c.GhClassicAccessToken),
+			}),
+		),
+	)
+	owners := map[string]registry.Owner{}
+	failed := map[string]bool{}
+
+	for _, path := range c.FilePaths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		s, err := parseSBOM(b)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %s", path)
+		}
+		if s.Name == "" {
+			s.Name = filepath.Base(path)
+		}
+
+		repos, err := c.resolve(ctx, resolver, s.Components)
+		if err != nil {
+			return err
+		}
+
+		var sponsorable, inserted, unknown int
+		for i, comp := range s.Components {
+			r, ok := repos[i]
+			if !ok {
+				continue
+			}
+			if failed[r.Owner] {
+				unknown++
+				continue
+			}
+
+			o, ok := owners[r.Owner]
+			if !ok {
+				o, err = registry.GetOwner(ctx, client, r.Owner)
+				if err != nil {
+					if ctx.Err() != nil {
+						return err
+					}
+					logger.WithError(err).Warnf("failed to look up %s", r.Owner)
+					failed[r.Owner] = true
+					unknown++
+					continue
+				}
+				owners[r.Owner] = o
+			}
+			if !o.Sponsorable {
+				continue
+			}
+			sponsorable++
+
+			if ok, reason := pol.Check(r.Owner, o.Typename); !ok {
+				logger.Infof("skipping %s: %s", r.Owner, reason)
+				continue
+			}
+
+			err = db.Tx(ctx, func(q *database.Queries) error {
+				err := q.InsertDonation(ctx, database.InsertDonationParams{
+					SponsorID:   string(c.Entity),
+					RecipientID: r.Owner,
+					LastTs:      time.Now().Unix(),
+				})
+				if err != nil {
+					return errors.Wrap(err, "failed to insert donation")
+				}
+
+				/* autoquery name: UpsertSbomComponent :exec
+
+				INSERT INTO sbom_components (sponsor_id, recipient_id, sbom, component, purl, last_ts)
+				VALUES (?, ?, ?, ?, ?, UNIXEPOCH())
+				ON CONFLICT (sponsor_id, recipient_id, sbom, component)
+				DO UPDATE SET purl = excluded.purl, last_ts = excluded.last_ts;
+				*/
+				err = q.UpsertSbomComponent(ctx, database.UpsertSbomComponentParams{
+					SponsorID:   string(c.Entity),
+					RecipientID: r.Owner,
+					Sbom:        s.Name,
+					Component:   comp.Name,
+					Purl:        comp.Purl,
+				})
+				return errors.Wrap(err, "failed to upsert sbom component")
+			})
+			if err != nil {
+				return err
+			}
+			inserted++
+			logger.Debugf("fundable %s for %s", r.Owner, comp.Name)
+		}
+
+		logger.Infof(
+			"%s: %d components, %d resolved to GitHub, %d sponsorable, %d imported, %d with failed owner lookups",
+			s.Name, len(s.Components), len(repos), sponsorable, inserted, unknown,
+		)
+	}
+	return nil
+}
+
+// resolve returns the GitHub repository of each component, by index, which
+// has one. Components which fail to resolve are logged and left out.
+func (c *CmdImportSbom) resolve(ctx context.Context, resolver *registry.Resolver, comps []component) (map[int]registry.Repo, error) {
+	names := make([]string, len(comps))
+	for i, comp := range comps {
+		names[i] = comp.Name
+	}
+	repos, err := registry.ResolveAll(ctx, names, c.Concurrency, func(ctx context.Context, i int) (registry.Repo, bool, error) {
+		return resolveComponent(ctx, resolver, comps[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[int]registry.Repo{}
+	for i, r := range repos {
+		if r.Owner != "" {
+			out[i] = r
+		}
+	}
+	return out, nil
+}
+
+func resolveComponent(ctx context.Context, resolver *registry.Resolver, comp component) (registry.Repo, bool, error) {
+	p, hasPurl := parsePurl(comp.Purl)
+	if hasPurl && p.Type == "github" && p.Namespace != "" {
+		return registry.Repo{Owner: p.Namespace, Name: p.Name}, true, nil
+	}
+
+	urls := comp.URLs
+	if hasPurl {
+		for _, q := range []string{"vcs_url", "repository_url", "download_url"} {
+			urls = append(urls, p.Qualifiers.Get(q))
+		}
+	}
+	for _, u := range urls {
+		if r, ok := registry.GitHubRepo(u); ok {
+			return r, true, nil
+		}
+	}
+
+	if !hasPurl {
+		return registry.Repo{}, false, nil
+	}
+	switch p.Type {
+	case registry.Golang, registry.Npm, registry.Pypi, registry.Cargo, registry.Gem:
+		return resolver.Resolve(ctx, p.Type, p.fullName())
+	}
+	return registry.Repo{}, false, nil
+}
@@ -0,0 +1,218 @@
+package importsbom
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/alecthomas/errors"
+)
+
+// component is a package listed in an SBOM.
+type component struct {
+	// Name is the component's name and version, eg. react@18.2.0.
+	Name string
+	Purl string
+	// URLs are the component's VCS, download and website references.
+	URLs []string
+}
+
+// sbom is the name of what an SBOM describes and the components it ships.
+type sbom struct {
+	Name       string
+	Components []component
+}
+
+// parseSBOM parses an SPDX 2.x or CycloneDX SBOM in JSON.
+func parseSBOM(b []byte) (sbom, error) {
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return sbom{}, errors.Wrap(err, "invalid json")
+	}
+	switch {
+	case doc.SPDXVersion != "":
+		return parseSPDX(b)
+	case doc.BOMFormat == "CycloneDX":
+		return parseCycloneDX(b)
+	}
+	return sbom{}, errors.New("neither an SPDX nor a CycloneDX SBOM")
+}
+
+func parseSPDX(b []byte) (sbom, error) {
+	var doc struct {
+		Name              string
+		DocumentDescribes []string
+		Packages          []struct {
+			SPDXID           string
+			Name             string
+			VersionInfo      string
+			DownloadLocation string
+			Homepage         string
+			SourceInfo       string
+			ExternalRefs     []struct {
+				ReferenceType    string
+				ReferenceLocator string
+			}
+		}
+		Relationships []struct {
+			SPDXElementID      string `json:"spdxElementId"`
+			RelationshipType   string
+			RelatedSPDXElement string
+		}
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return sbom{}, errors.Wrap(err, "invalid SPDX document")
+	}
+
+	// The packages the document describes are what was built, not what it
+	// depends on.
+	described := map[string]bool{}
+	for _, id := range doc.DocumentDescribes {
+		described[id] = true
+	}
+	for _, r := range doc.Relationships {
+		if r.SPDXElementID == "SPDXRef-DOCUMENT" && r.RelationshipType == "DESCRIBES" {
+			described[r.RelatedSPDXElement] = true
+		}
+	}
+
+	s := sbom{Name: doc.Name}
+	for _, p := range doc.Packages {
+		if described[p.SPDXID] {
+			continue
+		}
+		c := component{
+			Name: versioned(p.Name, p.VersionInfo),
+			URLs: []string{p.DownloadLocation, p.SourceInfo, p.Homepage},
+		}
+		for _, r := range p.ExternalRefs {
+			switch r.ReferenceType {
+			case "purl":
+				c.Purl = r.ReferenceLocator
+			case "vcs":
+				c.URLs = append([]string{r.ReferenceLocator}, c.URLs...)
+			}
+		}
+		s.Components = append(s.Components, c)
+	}
+	return s, nil
+}
+
+type cdxComponent struct {
+	Name               string
+	Group              string
+	Version            string
+	Purl               string
+	ExternalReferences []struct {
+		Type string
+		URL  string
+	}
+	Components []cdxComponent
+}
+
+func parseCycloneDX(b []byte) (sbom, error) {
+	var doc struct {
+		Metadata struct {
+			Component struct {
+				Name string
+			}
+		}
+		Components []cdxComponent
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return sbom{}, errors.Wrap(err, "invalid CycloneDX document")
+	}
+
+	s := sbom{Name: doc.Metadata.Component.Name}
+	var walk func(cs []cdxComponent)
+	walk = func(cs []cdxComponent) {
+		for _, cc := range cs {
+			name := cc.Name
+			if cc.Group != "" {
+				name = cc.Group + "/" + name
+			}
+			c := component{Name: versioned(name, cc.Version), Purl: cc.Purl}
+			// VCS references are the most precise, so they go first.
+			for _, r := range cc.ExternalReferences {
+				if r.Type == "vcs" {
+					c.URLs = append(c.URLs, r.URL)
+				}
+			}
+			for _, r := range cc.ExternalReferences {
+				if r.Type != "vcs" {
+					c.URLs = append(c.URLs, r.URL)
+				}
+			}
+			s.Components = append(s.Components, c)
+			walk(cc.Components)
+		}
+	}
+	walk(doc.Components)
+	return s, nil
+}
+
+func versioned(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
+
+// purl is a parsed package URL, eg. pkg:npm/%40babel/core@7.0.0 or
+// pkg:golang/github.com/alecthomas/kong@v0.8.0.
+type purl struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Qualifiers url.Values
+}
+
+func parsePurl(s string) (purl, bool) {
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return purl{}, false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+
+	var p purl
+	rest, query, _ := strings.Cut(rest, "?")
+	p.Qualifiers, _ = url.ParseQuery(query)
+
+	// The version follows the name, an @ before it is part of the namespace,
+	// eg. an unencoded npm scope.
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/") {
+		rest = rest[:i]
+	}
+	typ, path, ok := strings.Cut(strings.Trim(rest, "/"), "/")
+	if !ok {
+		return purl{}, false
+	}
+	p.Type = strings.ToLower(typ)
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		seg, err := url.PathUnescape(seg)
+		if err != nil {
+			return purl{}, false
+		}
+		segments[i] = seg
+	}
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[:len(segments)-1], "/")
+	return p, p.Name != ""
+}
+
+// fullName returns the package's name in its ecosystem, eg. @babel/core
+// or github.com/alecthomas/kong.
+func (p purl) fullName() string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
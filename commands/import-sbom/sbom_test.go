@@ -0,0 +1,154 @@
+package importsbom
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePurl(t *testing.T) {
+	tests := []struct {
+		purl string
+		want purl
+		ok   bool
+	}{
+		{
+			purl: "pkg:npm/%40babel/core@7.0.0",
+			want: purl{Type: "npm", Namespace: "@babel", Name: "core", Qualifiers: url.Values{}},
+			ok:   true,
+		},
+		{
+			purl: "pkg:npm/@scope/name",
+			want: purl{Type: "npm", Namespace: "@scope", Name: "name", Qualifiers: url.Values{}},
+			ok:   true,
+		},
+		{
+			purl: "pkg:npm/@scope/name@1.0.0",
+			want: purl{Type: "npm", Namespace: "@scope", Name: "name", Qualifiers: url.Values{}},
+			ok:   true,
+		},
+		{
+			purl: "pkg:golang/github.com/alecthomas/kong@v0.8.0",
+			want: purl{Type: "golang", Namespace: "github.com/alecthomas", Name: "kong", Qualifiers: url.Values{}},
+			ok:   true,
+		},
+		{
+			purl: "pkg:PyPI/requests@2.31.0?vcs_url=git%2Bhttps://github.com/psf/requests#src",
+			want: purl{
+				Type:       "pypi",
+				Name:       "requests",
+				Qualifiers: url.Values{"vcs_url": {"git+https://github.com/psf/requests"}},
+			},
+			ok: true,
+		},
+		{
+			purl: "pkg:github/owner/repo@v1",
+			want: purl{Type: "github", Namespace: "owner", Name: "repo", Qualifiers: url.Values{}},
+			ok:   true,
+		},
+		{purl: "pkg:npm"},
+		{purl: "pkg:npm/"},
+		{purl: "npm/react@18.0.0"},
+		{purl: ""},
+	}
+	for _, test := range tests {
+		got, ok := parsePurl(test.purl)
+		if ok != test.ok {
+			t.Errorf("parsePurl(%q) ok = %v, want %v", test.purl, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parsePurl(%q) = %+v, want %+v", test.purl, got, test.want)
+		}
+	}
+}
+
+func TestParseSPDX(t *testing.T) {
+	s, err := parseSBOM([]byte(`{
+		"spdxVersion": "SPDX-2.3",
+		"name": "service",
+		"documentDescribes": ["SPDXRef-service"],
+		"packages": [
+			{"SPDXID": "SPDXRef-service", "name": "service", "versionInfo": "1.0.0"},
+			{"SPDXID": "SPDXRef-app", "name": "app"},
+			{
+				"SPDXID": "SPDXRef-react",
+				"name": "react",
+				"versionInfo": "18.2.0",
+				"downloadLocation": "NOASSERTION",
+				"homepage": "https://react.dev",
+				"externalRefs": [
+					{"referenceType": "purl", "referenceLocator": "pkg:npm/react@18.2.0"},
+					{"referenceType": "vcs", "referenceLocator": "git+https://github.com/facebook/react.git"}
+				]
+			}
+		],
+		"relationships": [
+			{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-app"},
+			{"spdxElementId": "SPDXRef-app", "relationshipType": "DEPENDS_ON", "relatedSpdxElement": "SPDXRef-react"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sbom{
+		Name: "service",
+		Components: []component{{
+			Name: "react@18.2.0",
+			Purl: "pkg:npm/react@18.2.0",
+			URLs: []string{"git+https://github.com/facebook/react.git", "NOASSERTION", "", "https://react.dev"},
+		}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("parseSBOM() = %+v, want %+v", s, want)
+	}
+}
+
+func TestParseCycloneDX(t *testing.T) {
+	s, err := parseSBOM([]byte(`{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.5",
+		"metadata": {"component": {"name": "service"}},
+		"components": [
+			{
+				"name": "core",
+				"group": "@babel",
+				"version": "7.0.0",
+				"purl": "pkg:npm/%40babel/core@7.0.0",
+				"externalReferences": [
+					{"type": "website", "url": "https://babeljs.io"},
+					{"type": "vcs", "url": "https://github.com/babel/babel"}
+				],
+				"components": [
+					{"name": "nested"}
+				]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sbom{
+		Name: "service",
+		Components: []component{
+			{
+				Name: "@babel/core@7.0.0",
+				Purl: "pkg:npm/%40babel/core@7.0.0",
+				URLs: []string{"https://github.com/babel/babel", "https://babeljs.io"},
+			},
+			{Name: "nested"},
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("parseSBOM() = %+v, want %+v", s, want)
+	}
+}
+
+func TestParseSBOMUnknown(t *testing.T) {
+	for _, text := range []string{`{"bomFormat": "other"}`, `{invalid`} {
+		_, err := parseSBOM([]byte(text))
+		if err == nil {
+			t.Errorf("parseSBOM(%s) succeeded", text)
+		}
+	}
+}
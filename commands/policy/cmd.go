@@ -0,0 +1,131 @@
+//go:generate autoquery
+package policy
+
+//
+// Manage the recipient policy file (--policy-path). The policy is enforced
+// when donations are inserted by animate-repos and import, and again
+// by donate, which also resolves the account type of each recipient if
+// any rule needs it.
+//
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+type CmdPolicy struct {
+	Show   CmdShow   `cmd:"" default:"1" help:"Print the policy rules."`
+	Allow  CmdAllow  `cmd:"" help:"Add an allow rule."`
+	Deny   CmdDeny   `cmd:"" help:"Add a deny rule."`
+	Remove CmdRemove `cmd:"" help:"Remove a rule by its list and index."`
+	Check  CmdCheck  `cmd:"" help:"Check recipients against the policy."`
+}
+
+type ruleFlags struct {
+	Login   string `help:"Match this login exactly."`
+	Pattern string `help:"Match logins against this glob pattern, eg. 'acme-*'."`
+	Type    string `help:"Match this account type (${enum})." enum:",user,organization" default:""`
+	Reason  string `help:"Why the rule exists."`
+}
+
+func (f ruleFlags) rule() (policy.Rule, error) {
+	r := policy.Rule{Login: f.Login, Pattern: f.Pattern, Type: f.Type, Reason: f.Reason}
+	return r, r.Validate()
+}
+
+type CmdShow struct{}
+
+func (c *CmdShow) Run(pol *policy.Policy) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LIST\tINDEX\tRULE")
+	for i, r := range pol.Allow {
+		fmt.Fprintf(tw, "allow\t%d\t%s\n", i, r)
+	}
+	for i, r := range pol.Deny {
+		fmt.Fprintf(tw, "deny\t%d\t%s\n", i, r)
+	}
+	return tw.Flush()
+}
+
+type CmdAllow struct {
+	ruleFlags
+}
+
+func (c *CmdAllow) Run(ctx context.Context, pol *policy.Policy) error {
+	r, err := c.rule()
+	if err != nil {
+		return err
+	}
+	pol.Allow = append(pol.Allow, r)
+	log.FromContext(ctx).Infof("allowing %s", r)
+	return pol.Save()
+}
+
+type CmdDeny struct {
+	ruleFlags
+}
+
+func (c *CmdDeny) Run(ctx context.Context, pol *policy.Policy) error {
+	r, err := c.rule()
+	if err != nil {
+		return err
+	}
+	pol.Deny = append(pol.Deny, r)
+	log.FromContext(ctx).Infof("denying %s", r)
+	return pol.Save()
+}
+
+type CmdRemove struct {
+	List  string `arg:"" help:"The list to remove the rule from (${enum})." enum:"allow,deny"`
+	Index int    `arg:"" help:"The index of the rule as printed by show."`
+}
+
+func (c *CmdRemove) Run(ctx context.Context, pol *policy.Policy) error {
+	rules := &pol.Allow
+	if c.List == "deny" {
+		rules = &pol.Deny
+	}
+	if c.Index < 0 || c.Index >= len(*rules) {
+		return errors.Errorf("no %s rule %d", c.List, c.Index)
+	}
+	log.FromContext(ctx).Infof("removing %s rule %s", c.List, (*rules)[c.Index])
+	*rules = append((*rules)[:c.Index], (*rules)[c.Index+1:]...)
+	return pol.Save()
+}
+
+type CmdCheck struct {
+	Logins []string `arg:"" optional:"" help:"The logins to check. Defaults to every recipient in the donations table."`
+	Type   string   `help:"The account type of the logins (${enum})." enum:",user,organization" default:""`
+}
+
+func (c *CmdCheck) Run(ctx context.Context, db *database.DB, pol *policy.Policy) error {
+	logins := c.Logins
+	if len(logins) == 0 {
+		/* autoquery name: GetRecipients :many
+
+		SELECT DISTINCT recipient_id
+		FROM donations
+		ORDER BY recipient_id;
+		*/
+		var err error
+		logins, err = db.GetRecipients(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get recipients")
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOGIN\tALLOWED\tREASON")
+	for _, login := range logins {
+		ok, reason := pol.Check(login, c.Type)
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", login, ok, reason)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,193 @@
+//go:generate autoquery
+package prune
+
+//
+// Prune cancels recurring sponsorships whose recipient none of the
+// sponsor's repos depends on anymore: every dependency edge pointing to it
+// has been stale for --grace-period, ie. its repo was removed or marked
+// inactive, or the last completed animation didn't see it. Donations
+// without edges, eg. imported or only added from a FUNDING.yml, are never
+// pruned. Run reconcile first so the sponsorships table is up to date.
+//
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/shurcooL/githubv4"
+	utils "github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
+	"github.com/thnxdev/utils/utils/log"
+	"golang.org/x/oauth2"
+)
+
+type CmdPrune struct {
+	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
+	GracePeriod          time.Duration       `help:"How long a recipient must be gone before its sponsorship is cancelled." default:"720h"`
+	DryRun               bool                `help:"Print the sponsorships which would be cancelled without cancelling them."`
+}
+
+func (c *CmdPrune) Run(
+	ctx context.Context,
+	db *database.DB,
+) error {
+	logger := log.FromContext(ctx)
+	logger.Info("starting")
+
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	)
+
+	client := githubv4.NewClient(
+		oauth2.NewClient(
+			hctx,
+			oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: string(c.GhClassicAccessToken),
+			}),
+		),
+	)
+
+	/* autoquery name: GetPrunable :many
+
+	SELECT
+		s.sponsor_id,
+		s.recipient_id,
+		s.tier_name,
+		s.amount,
+		CAST(MAX(CASE
+			WHEN d.excluded_by != '' THEN d.excluded_ts
+			WHEN
+				r.inactive_reason != '' AND
+				(d.last_ts >= r.animate_start_ts OR r.animate_ts < r.animate_start_ts)
+			THEN MAX(d.last_ts, r.inactive_ts)
+			ELSE d.last_ts
+		END) AS INTEGER) AS last_seen_ts
+	FROM sponsorships s
+	JOIN donations dn ON
+		dn.sponsor_id = s.sponsor_id AND
+		dn.recipient_id = s.recipient_id COLLATE NOCASE
+	JOIN manifests m ON m.owner_name = s.sponsor_id
+	JOIN dependencies d ON
+		d.manifest_id = m.id AND
+		d.dep_owner_name = s.recipient_id AND
+		d.is_sponsorable
+	LEFT JOIN repos r ON
+		r.owner_name = m.owner_name AND
+		r.repo_name = m.repo_name
+	WHERE
+		s.is_active AND
+		NOT s.is_one_time AND
+		NOT EXISTS (
+			SELECT 1
+			FROM dependencies cd
+			JOIN manifests cm ON cm.id = cd.manifest_id
+			JOIN repos r ON
+				r.owner_name = cm.owner_name AND
+				r.repo_name = cm.repo_name AND
+				r.inactive_reason = ''
+			WHERE
+				cm.owner_name = s.sponsor_id AND
+				cd.dep_owner_name = s.recipient_id AND
+				cd.is_sponsorable AND
+				cd.excluded_by = '' AND
+				(cd.last_ts >= r.animate_start_ts OR r.animate_ts < r.animate_start_ts)
+		)
+	GROUP BY s.sponsor_id, s.recipient_id, s.tier_name, s.amount
+	HAVING last_seen_ts < UNIXEPOCH() - CAST(sqlc.arg(grace_period) AS INTEGER)
+	ORDER BY s.sponsor_id, s.recipient_id;
+	*/
+	rows, err := db.GetPrunable(ctx, int64(c.GracePeriod.Seconds()))
+	if err != nil {
+		return errors.Wrap(err, "failed to get prunable sponsorships")
+	}
+	if len(rows) == 0 {
+		logger.Info("no sponsorships to prune")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPONSOR\tRECIPIENT\tTIER\tAMOUNT\tLAST SEEN\tSTATUS")
+	for _, row := range rows {
+		status := "dry-run"
+		if !c.DryRun {
+			status = "cancelled"
+			err = c.cancel(ctx, db, client, row.SponsorID, row.RecipientID)
+			if err != nil {
+				logger.WithError(err).Errorf("failed to cancel sponsorship of %s by %s", row.RecipientID, row.SponsorID)
+				status = "failed"
+			}
+		}
+		lastSeen := time.Unix(row.LastSeenTs, 0).UTC().Format("2006-01-02")
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%s\t%s\n",
+			row.SponsorID,
+			row.RecipientID,
+			row.TierName,
+			row.Amount,
+			lastSeen,
+			status,
+		)
+	}
+	return tw.Flush()
+}
+
+// cancel cancels the sponsorship of recipient by sponsor on GitHub and
+// records the cancellation.
+func (c *CmdPrune) cancel(
+	ctx context.Context,
+	db *database.DB,
+	client *githubv4.Client,
+	sponsor, recipient string,
+) error {
+	var m struct {
+		CancelSponsorship struct {
+			ClientMutationID string
+		} `graphql:"cancelSponsorship(input:$input)"`
+	}
+	input := githubv4.CancelSponsorshipInput{
+		SponsorLogin:     githubv4.NewString(githubv4.String(sponsor)),
+		SponsorableLogin: githubv4.NewString(githubv4.String(recipient)),
+	}
+	err := client.Mutate(ctx, &m, input, nil)
+	if err != nil {
+		return err
+	}
+
+	/* autoquery name: CancelSponsorship :exec
+
+	UPDATE sponsorships
+	SET is_active = FALSE, cancel_ts = UNIXEPOCH()
+	WHERE sponsor_id = ? AND recipient_id = ?;
+	*/
+	err = db.CancelSponsorship(ctx, database.CancelSponsorshipParams{
+		SponsorID:   sponsor,
+		RecipientID: recipient,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to record cancelled sponsorship")
+	}
+
+	/* autoquery name: CancelDonation :exec
+
+	UPDATE donations
+	SET cancel_ts = UNIXEPOCH()
+	WHERE sponsor_id = ? AND recipient_id = ? COLLATE NOCASE;
+	*/
+	err = db.CancelDonation(ctx, database.CancelDonationParams{
+		SponsorID:   sponsor,
+		RecipientID: recipient,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to record cancelled donation")
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+//go:generate autoquery
+package reconcile
+
+//
+// Reconcile pages through the active sponsorships of each sponsor entity
+// on GitHub and records them in the sponsorships table. Donations which
+// are already covered by an active sponsorship are marked as donated so
+// donate doesn't create a duplicate. One-time sponsorships only cover
+// donations in donate's --period they were created in. Sponsorships on
+// GitHub which aren't in the donations table are reported. Recipients are
+// matched regardless of case, as logins typed into imports may not use
+// GitHub's.
+//
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/shurcooL/githubv4"
+	utils "github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/commands/donate"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/httpgh"
+	"github.com/thnxdev/utils/utils/log"
+	"golang.org/x/oauth2"
+)
+
+type CmdReconcile struct {
+	GhClassicAccessToken utils.GhAccessToken `help:"GitHub classis access token with admin:org & user scopes." required:"" env:"GH_CLASSIC_ACCESS_TOKEN"`
+	Entities             []utils.Entity      `help:"The GitHub entities to reconcile. Defaults to every sponsor in the donations table."`
+	Period               string              `help:"How often donate repeats one-time donations (${enum})." enum:"month,week,day" default:"month"`
+	Timezone             string              `help:"The timezone donate's periods start in." default:"UTC"`
+}
+
+func (c *CmdReconcile) Run(
+	ctx context.Context,
+	db *database.DB,
+) error {
+	logger := log.FromContext(ctx)
+	logger.Info("starting")
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return errors.Wrapf(err, "invalid timezone %q", c.Timezone)
+	}
+
+	hctx := context.WithValue(
+		ctx,
+		oauth2.HTTPClient,
+		&http.Client{Transport: httpgh.NewTransport(nil)},
+	)
+
+	client := githubv4.NewClient(
+		oauth2.NewClient(
+			hctx,
+			oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: string(c.GhClassicAccessToken),
+			}),
+		),
+	)
+
+	sponsors := make([]string, 0, len(c.Entities))
+	for _, e := range c.Entities {
+		sponsors = append(sponsors, string(e))
+	}
+	if len(sponsors) == 0 {
+		/* autoquery name: GetSponsors :many
+
+		SELECT DISTINCT sponsor_id
+		FROM donations
+		ORDER BY sponsor_id;
+		*/
+		sponsors, err = db.GetSponsors(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get sponsors")
+		}
+	}
+
+	for _, sponsor := range sponsors {
+		err := c.syncSponsor(ctx, db, client, sponsor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sync sponsorships of %s", sponsor)
+		}
+	}
+
+	/* autoquery name: MarkDonationsSponsored :execrows
+
+	UPDATE donations
+	SET
+		donate_ts = UNIXEPOCH(),
+		amount = (
+			SELECT s.amount
+			FROM sponsorships s
+			WHERE
+				s.sponsor_id = donations.sponsor_id AND
+				s.recipient_id = donations.recipient_id COLLATE NOCASE
+		)
+	WHERE
+		donate_ts < last_ts AND
+		EXISTS (
+			SELECT 1
+			FROM sponsorships s
+			WHERE
+				s.sponsor_id = donations.sponsor_id AND
+				s.recipient_id = donations.recipient_id COLLATE NOCASE AND
+				s.is_active AND
+				(NOT s.is_one_time OR s.created_ts >= sqlc.arg(since_ts))
+		);
+	*/
+	marked, err := db.MarkDonationsSponsored(ctx, donate.PeriodStart(time.Now().In(loc), c.Period).Unix())
+	if err != nil {
+		return errors.Wrap(err, "failed to mark sponsored donations")
+	}
+	logger.Infof("%d donations already satisfied by existing sponsorships", marked)
+
+	/* autoquery name: GetUnknownSponsorships :many
+
+	SELECT s.sponsor_id, s.recipient_id, s.tier_name, s.amount, s.is_one_time, s.created_ts
+	FROM sponsorships s
+	LEFT JOIN donations d ON
+		d.sponsor_id = s.sponsor_id AND
+		d.recipient_id = s.recipient_id COLLATE NOCASE
+	WHERE s.is_active AND d.id IS NULL
+	ORDER BY s.sponsor_id, s.recipient_id;
+	*/
+	unknown, err := db.GetUnknownSponsorships(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get unknown sponsorships")
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	logger.Warnf("%d active sponsorships are not in the donations table", len(unknown))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPONSOR\tRECIPIENT\tTIER\tAMOUNT\tONE TIME\tSINCE")
+	for _, s := range unknown {
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%t\t%s\n",
+			s.SponsorID,
+			s.RecipientID,
+			s.TierName,
+			s.Amount,
+			s.IsOneTime,
+			time.Unix(s.CreatedTs, 0).UTC().Format("2006-01-02"),
+		)
+	}
+	return tw.Flush()
+}
+
+// syncSponsor records every active sponsorship of sponsor and marks the
+// ones no longer returned by GitHub as inactive.
+func (c *CmdReconcile) syncSponsor(
+	ctx context.Context,
+	db *database.DB,
+	client *githubv4.Client,
+	sponsor string,
+) error {
+	logger := log.FromContext(ctx)
+	syncTs := time.Now().Unix()
+
+	var cursor *githubv4.String
+	for {
+		var q struct {
+			RepositoryOwner struct {
+				Sponsorable struct {
+					SponsorshipsAsSponsor struct {
+						Nodes []struct {
+							ID               string
+							CreatedAt        githubv4.DateTime
+							IsOneTimePayment bool
+							PrivacyLevel     githubv4.SponsorshipPrivacy
+							Tier             struct {
+								ID                    string
+								Name                  string
+								MonthlyPriceInDollars int
+							}
+							Sponsorable struct {
+								RepositoryOwner struct {
+									Login string
+								} `graphql:"... on RepositoryOwner"`
+							}
+						}
+						PageInfo struct {
+							EndCursor   string
+							HasNextPage bool
+						}
+					} `graphql:"sponsorshipsAsSponsor(first: 100, after: $cursor, activeOnly: true)"`
+				} `graphql:"... on Sponsorable"`
+			} `graphql:"repositoryOwner(login: $login)"`
+		}
+		var vars map[string]any = map[string]any{
+			"login":  githubv4.String(sponsor),
+			"cursor": cursor,
+		}
+
+		err := client.Query(ctx, &q, vars)
+		if err != nil {
+			return err
+		}
+
+		sponsorships := q.RepositoryOwner.Sponsorable.SponsorshipsAsSponsor
+		for _, s := range sponsorships.Nodes {
+			logger.Debugf(
+				"%s sponsors %s ($%d)",
+				sponsor,
+				s.Sponsorable.RepositoryOwner.Login,
+				s.Tier.MonthlyPriceInDollars,
+			)
+
+			/* autoquery name: UpsertSponsorship :exec
+
+			INSERT INTO sponsorships (
+				sponsor_id,
+				recipient_id,
+				gh_id,
+				tier_id,
+				tier_name,
+				amount,
+				is_one_time,
+				privacy_level,
+				created_ts,
+				is_active,
+				sync_ts
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?)
+			ON CONFLICT (sponsor_id, recipient_id)
+			DO UPDATE SET
+				gh_id = excluded.gh_id,
+				tier_id = excluded.tier_id,
+				tier_name = excluded.tier_name,
+				amount = excluded.amount,
+				is_one_time = excluded.is_one_time,
+				privacy_level = excluded.privacy_level,
+				created_ts = excluded.created_ts,
+				is_active = TRUE,
+				cancel_ts = 0,
+				sync_ts = excluded.sync_ts;
+			*/
+			err = db.UpsertSponsorship(ctx, database.UpsertSponsorshipParams{
+				SponsorID:    sponsor,
+				RecipientID:  s.Sponsorable.RepositoryOwner.Login,
+				GhID:         s.ID,
+				TierID:       s.Tier.ID,
+				TierName:     s.Tier.Name,
+				Amount:       int64(s.Tier.MonthlyPriceInDollars),
+				IsOneTime:    s.IsOneTimePayment,
+				PrivacyLevel: string(s.PrivacyLevel),
+				CreatedTs:    s.CreatedAt.Unix(),
+				SyncTs:       syncTs,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to upsert sponsorship")
+			}
+		}
+
+		if !sponsorships.PageInfo.HasNextPage {
+			break
+		}
+		cursor = githubv4.NewString(githubv4.String(sponsorships.PageInfo.EndCursor))
+	}
+
+	/* autoquery name: DeactivateStaleSponsorships :exec
+
+	UPDATE sponsorships
+	SET is_active = FALSE
+	WHERE sponsor_id = ? AND sync_ts < ?;
+	*/
+	err := db.DeactivateStaleSponsorships(ctx, database.DeactivateStaleSponsorshipsParams{
+		SponsorID: sponsor,
+		SyncTs:    syncTs,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to deactivate stale sponsorships")
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+//go:generate autoquery
+package run
+
+//
+// run chains the sponsorship pipeline in one long-running process: the
+// dl-repos, animate-repos and donate stages are run on their own
+// intervals, in that order whenever several are due at once. Each stage
+// takes the same flags as its command, prefixed with the stage's name,
+// eg. --donate-amount. GH_CLASSIC_ACCESS_TOKEN sets the token of every
+// stage.
+//
+// When each stage last started and ended, and the error it failed with,
+// are stored in the schedule table, so a restarted run only runs the
+// stages which are due. A stage which was interrupted is run again right
+// away. A stage which fails is logged and retried at its next interval.
+// An interval of 0 disables the stage.
+//
+// SIGINT and SIGTERM cancel the running stage and stop the run. donate
+// holds the donate lease while it creates sponsorships, so a donate
+// stage never overlaps a donate run started by hand or by another run.
+//
+
+import (
+	"context"
+	"time"
+
+	"github.com/alecthomas/errors"
+
+	animaterepos "github.com/thnxdev/utils/commands/animate-repos"
+	dlrepos "github.com/thnxdev/utils/commands/dl-repos"
+	"github.com/thnxdev/utils/commands/donate"
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/log"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+type CmdRun struct {
+	DlReposEvery      time.Duration `help:"How often to run dl-repos, 0 to disable it." default:"24h"`
+	AnimateReposEvery time.Duration `help:"How often to run animate-repos, 0 to disable it." default:"1h"`
+	DonateEvery       time.Duration `help:"How often to run donate, 0 to disable it." default:"24h"`
+
+	DlRepos      dlrepos.CmdDlRepos           `embed:"" prefix:"dl-repos-"`
+	AnimateRepos animaterepos.CmdAnimateRepos `embed:"" prefix:"animate-repos-"`
+	Donate       donate.CmdDonate             `embed:"" prefix:"donate-"`
+}
+
+// Validate requires the flags of enabled stages which their commands
+// require, but which can't be required flags of run as the stages may be
+// disabled.
+func (c *CmdRun) Validate() error {
+	if c.DlReposEvery > 0 && len(c.DlRepos.Entities) == 0 {
+		return errors.New("missing flags: --dl-repos-entities, or disable dl-repos with --dl-repos-every=0")
+	}
+	if c.AnimateReposEvery > 0 {
+		if err := c.AnimateRepos.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.DonateEvery > 0 {
+		return c.Donate.Validate()
+	}
+	return nil
+}
+
+// stage is a step of the pipeline.
+type stage struct {
+	Name  string
+	Every time.Duration
+	Run   func(ctx context.Context) error
+}
+
+func (c *CmdRun) Run(
+	ctx context.Context,
+	db *database.DB,
+	pol *policy.Policy,
+) error {
+	logger := log.FromContext(ctx)
+	logger.Info("starting")
+
+	stages := c.stages(db, pol)
+
+	for {
+		next, err := runDue(ctx, db, stages)
+		if ctx.Err() != nil {
+			logger.Info("stopping")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if next.IsZero() {
+			return errors.New("every stage is disabled")
+		}
+
+		logger.Debugf("sleeping until %s", next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			logger.Info("stopping")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// stages returns the pipeline's stages in the order they run.
+func (c *CmdRun) stages(db *database.DB, pol *policy.Policy) []stage {
+	return []stage{
+		{"dl-repos", c.DlReposEvery, func(ctx context.Context) error { return c.DlRepos.Run(ctx, db) }},
+		{"animate-repos", c.AnimateReposEvery, func(ctx context.Context) error { return c.AnimateRepos.Run(ctx, db, pol) }},
+		{"donate", c.DonateEvery, func(ctx context.Context) error { return c.Donate.Run(ctx, db, pol) }},
+	}
+}
+
+// runDue runs the stages which are due, in order, and returns when the
+// next one is due. It's zero if every stage is disabled.
+func runDue(ctx context.Context, db *database.DB, stages []stage) (time.Time, error) {
+	logger := log.FromContext(ctx)
+
+	/* autoquery name: GetSchedule :many
+
+	SELECT stage, start_ts, end_ts, error
+	FROM schedule;
+	*/
+	rows, err := db.GetSchedule(ctx)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get schedule")
+	}
+	schedule := map[string]database.Schedule{}
+	for _, r := range rows {
+		schedule[r.Stage] = r
+	}
+
+	var next time.Time
+	for _, s := range stages {
+		if s.Every <= 0 {
+			continue
+		}
+
+		due := dueAt(schedule[s.Name], s.Every)
+		if time.Now().Before(due) {
+			if next.IsZero() || due.Before(next) {
+				next = due
+			}
+			continue
+		}
+
+		start := time.Now()
+
+		/* autoquery name: StartStage :exec
+
+		INSERT INTO schedule (stage, start_ts)
+		VALUES (?, UNIXEPOCH())
+		ON CONFLICT (stage)
+		DO UPDATE SET start_ts = excluded.start_ts, end_ts = 0;
+		*/
+		err := db.StartStage(ctx, s.Name)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to start stage")
+		}
+
+		logger.Infof("running %s", s.Name)
+		serr := s.Run(log.LoggerContext(ctx, logger.WithField("stage", s.Name)))
+		if ctx.Err() != nil {
+			// Left started so it's run again by the next run.
+			return time.Time{}, nil
+		}
+		failure := ""
+		if serr != nil {
+			logger.WithError(serr).Errorf("%s failed", s.Name)
+			failure = serr.Error()
+		}
+
+		/* autoquery name: EndStage :exec
+
+		UPDATE schedule
+		SET end_ts = UNIXEPOCH(), error = ?
+		WHERE stage = ?;
+		*/
+		err = db.EndStage(ctx, database.EndStageParams{
+			Error: failure,
+			Stage: s.Name,
+		})
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to end stage")
+		}
+
+		due = start.Add(s.Every)
+		if next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+	return next, nil
+}
+
+// dueAt returns when the stage is next due: every after it last started,
+// or now if it never ran or didn't finish.
+func dueAt(s database.Schedule, every time.Duration) time.Time {
+	if s.StartTs == 0 || s.EndTs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s.StartTs, 0).Add(every)
+}
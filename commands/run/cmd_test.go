@@ -0,0 +1,206 @@
+package run
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thnxdev/utils/database"
+	"github.com/thnxdev/utils/utils/policy"
+)
+
+// recorder returns a stage which appends its name to ran when it runs and
+// returns err.
+func recorder(ran *[]string, name string, every time.Duration, err error) stage {
+	return stage{name, every, func(context.Context) error {
+		*ran = append(*ran, name)
+		return err
+	}}
+}
+
+func TestRunDueOrderAndInterval(t *testing.T) {
+	ctx := context.Background()
+	db, _ := database.OpenTest(t)
+
+	var ran []string
+	stages := []stage{
+		recorder(&ran, "a", time.Hour, nil),
+		recorder(&ran, "b", time.Minute, nil),
+	}
+	start := time.Now()
+	next, err := runDue(ctx, db, stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if next.Before(start.Add(time.Minute-time.Second)) || next.After(time.Now().Add(time.Minute)) {
+		t.Errorf("next = %s, want in a minute", next)
+	}
+
+	// Neither is due yet.
+	ran = nil
+	_, err = runDue(ctx, db, stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 {
+		t.Errorf("ran %v before they were due", ran)
+	}
+}
+
+func TestRunDueDisabled(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	var ran []string
+	next, err := runDue(ctx, db, []stage{
+		recorder(&ran, "a", 0, nil),
+		recorder(&ran, "b", -time.Hour, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 || !next.IsZero() {
+		t.Errorf("ran %v, next = %s, want nothing", ran, next)
+	}
+
+	var n int
+	err = conn.QueryRow(`SELECT COUNT(*) FROM schedule`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d stages scheduled, want none", n)
+	}
+}
+
+func TestRunDueRestartsInterrupted(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	// Every stage ran before and is due again.
+	var ran []string
+	_, err := runDue(ctx, db, []stage{
+		recorder(&ran, "a", time.Hour, nil),
+		recorder(&ran, "b", time.Hour, nil),
+		recorder(&ran, "c", time.Hour, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`UPDATE schedule SET start_ts = start_ts - 3600`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The run is stopped while the second stage runs, within the second
+	// the last run ended in.
+	ran = nil
+	sctx, stop := context.WithCancel(ctx)
+	defer stop()
+	_, err = runDue(sctx, db, []stage{
+		recorder(&ran, "a", time.Hour, nil),
+		{"b", time.Hour, func(context.Context) error {
+			ran = append(ran, "b")
+			stop()
+			return context.Canceled
+		}},
+		recorder(&ran, "c", time.Hour, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+
+	var endTs int64
+	err = conn.QueryRow(`SELECT end_ts FROM schedule WHERE stage = 'b'`).Scan(&endTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endTs != 0 {
+		t.Errorf("interrupted stage ended at %d", endTs)
+	}
+
+	// The restarted run runs the interrupted stage and the one after it,
+	// but not the one which finished.
+	ran = nil
+	_, err = runDue(ctx, db, []stage{
+		recorder(&ran, "a", time.Hour, nil),
+		recorder(&ran, "b", time.Hour, nil),
+		recorder(&ran, "c", time.Hour, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("restart ran %v, want %v", ran, want)
+	}
+}
+
+func TestRunDueDonateLeaseHeld(t *testing.T) {
+	ctx := context.Background()
+	db, conn := database.OpenTest(t)
+
+	// Another donate run holds the lease.
+	_, err := conn.Exec(`INSERT INTO leases (name, holder, expires_ts) VALUES ('donate', 'other', UNIXEPOCH() + 600)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &CmdRun{DonateEvery: time.Hour}
+	c.Donate.LeaseTimeout = time.Minute
+	start := time.Now()
+	next, err := runDue(ctx, db, c.stages(db, &policy.Policy{}))
+	if err != nil {
+		t.Fatalf("a failing stage stopped the run: %v", err)
+	}
+
+	// The failure is recorded and the stage retried at its next interval.
+	var failure string
+	err = conn.QueryRow(`SELECT error FROM schedule WHERE stage = 'donate' AND end_ts >= start_ts`).Scan(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure != "another donate run is in progress" {
+		t.Errorf("error = %q, want the held lease", failure)
+	}
+	if next.Before(start.Add(time.Hour - time.Second)) {
+		t.Errorf("next = %s, want in an hour", next)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := &CmdRun{DlReposEvery: time.Hour}
+	if c.Validate() == nil {
+		t.Error("enabled dl-repos without entities is valid")
+	}
+	c.DlReposEvery = 0
+	if err := c.Validate(); err != nil {
+		t.Errorf("disabled dl-repos without entities: %v", err)
+	}
+
+	c.DonateEvery = time.Hour
+	c.Donate.LeaseTimeout = 500 * time.Millisecond
+	if c.Validate() == nil {
+		t.Error("enabled donate with a lease timeout under a second is valid")
+	}
+	c.Donate.LeaseTimeout = time.Minute
+	if err := c.Validate(); err != nil {
+		t.Errorf("enabled donate with a lease timeout: %v", err)
+	}
+
+	c.AnimateReposEvery = time.Hour
+	c.AnimateRepos.LeaseTimeout = time.Minute
+	if c.Validate() == nil {
+		t.Error("enabled animate-repos without workers is valid")
+	}
+	c.AnimateRepos.Concurrency = 1
+	if err := c.Validate(); err != nil {
+		t.Errorf("enabled animate-repos with a worker: %v", err)
+	}
+}
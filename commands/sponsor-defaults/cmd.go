@@ -0,0 +1,88 @@
+//go:generate autoquery
+package sponsordefaults
+
+//
+// Sponsor defaults are the privacy level and email preference donate uses
+// for a sponsor's donations which don't set their own, eg. from the
+// privacy_level and receive_emails columns of import. Without defaults
+// sponsorships are public and don't receive emails. Only the defaults
+// which are passed are changed.
+//
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/errors"
+	utils "github.com/thnxdev/utils"
+	"github.com/thnxdev/utils/database"
+)
+
+type CmdSponsorDefaults struct {
+	Entity        utils.Entity `help:"The sponsor entity to set the defaults of. Prints all defaults if omitted."`
+	PrivacyLevel  *string      `help:"Whether the entity's sponsorships are public or private (${enum}). Left as is if omitted, public for a new entity." enum:"public,private"`
+	ReceiveEmails *bool        `help:"Whether the entity receives email updates from recipients. Left as is if omitted, false for a new entity." negatable:""`
+}
+
+func (c *CmdSponsorDefaults) Run(
+	ctx context.Context,
+	db *database.DB,
+) error {
+	if c.Entity != "" {
+		params := database.UpdateSponsorDefaultsParams{SponsorID: string(c.Entity)}
+		if c.PrivacyLevel != nil {
+			params.PrivacyLevel = database.String(strings.ToUpper(*c.PrivacyLevel))
+		}
+		if c.ReceiveEmails != nil {
+			params.ReceiveEmails = database.Bool(*c.ReceiveEmails)
+		}
+
+		err := db.Tx(ctx, func(q *database.Queries) error {
+			/* autoquery name: InsertSponsorDefaults :exec
+
+			INSERT INTO sponsor_defaults (sponsor_id, privacy_level, receive_emails)
+			VALUES (?, 'PUBLIC', FALSE)
+			ON CONFLICT (sponsor_id)
+			DO NOTHING;
+			*/
+			err := q.InsertSponsorDefaults(ctx, string(c.Entity))
+			if err != nil {
+				return err
+			}
+
+			/* autoquery name: UpdateSponsorDefaults :exec
+
+			UPDATE sponsor_defaults
+			SET
+				privacy_level = COALESCE(?, privacy_level),
+				receive_emails = COALESCE(?, receive_emails)
+			WHERE sponsor_id = ?;
+			*/
+			return q.UpdateSponsorDefaults(ctx, params)
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to set sponsor defaults")
+		}
+	}
+
+	/* autoquery name: GetSponsorDefaults :many
+
+	SELECT sponsor_id, privacy_level, receive_emails
+	FROM sponsor_defaults
+	ORDER BY sponsor_id;
+	*/
+	defaults, err := db.GetSponsorDefaults(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get sponsor defaults")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPONSOR\tPRIVACY\tEMAILS")
+	for _, d := range defaults {
+		fmt.Fprintf(tw, "%s\t%s\t%t\n", d.SponsorID, d.PrivacyLevel, d.ReceiveEmails)
+	}
+	return tw.Flush()
+}
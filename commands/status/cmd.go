@@ -0,0 +1,223 @@
+//go:generate autoquery
+package status
+
+//
+// Status summarises the database for a review of the pipeline:
+//	- repos: how many there are, how many are inactive, animated or
+//	  pending animation, and the cursors of the ones animate-repos is
+//	  part way through;
+//	- donations: how many are pending, how many are retrying after a
+//	  transient failure, how many failed permanently or were cancelled by
+//	  prune, and what was donated in the month;
+//	- the failing donations with the reason of their last failure;
+//	- the same donation totals per sponsor.
+// Pending donations are the ones a donate run with the same
+// --is-recurring, --period and --timezone would donate now, before the
+// recipient policy and monthly budget are applied.
+// What was donated in a month is read from donation_events, so it only
+// counts sponsorships donate created in that month, in --timezone, not
+// renewals of recurring ones by GitHub.
+//
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/alecthomas/errors"
+	"github.com/thnxdev/utils/commands/donate"
+	"github.com/thnxdev/utils/database"
+)
+
+type CmdStatus struct {
+	Format      string `help:"The format to print the status in (${enum})." enum:"table,json,csv" default:"table"`
+	Month       string `help:"The month to total donations for (YYYY-MM), the current one by default." placeholder:"YYYY-MM"`
+	Timezone    string `help:"The timezone months and donate's periods start in." default:"UTC"`
+	IsRecurring bool   `help:"Whether donate makes donations recurring monthly, for the pending ones." default:"true"`
+	Period      string `help:"How often donate repeats one-time donations (${enum}), for the pending ones." enum:"month,week,day" default:"month"`
+}
+
+func (c *CmdStatus) Run(
+	ctx context.Context,
+	db *database.DB,
+) error {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return errors.Wrapf(err, "invalid timezone %q", c.Timezone)
+	}
+	now := time.Now().In(loc)
+
+	month := c.Month
+	if month == "" {
+		month = now.Format("2006-01")
+	}
+	monthStart, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return errors.Errorf("invalid month %q, expected YYYY-MM", month)
+	}
+
+	r := report{Month: month}
+
+	/* autoquery name: GetRepoStatus :one
+
+	SELECT
+		COUNT(*) AS total,
+		CAST(COALESCE(SUM(inactive_reason != ''), 0) AS INTEGER) AS inactive,
+		CAST(COALESCE(SUM(inactive_reason = '' AND animate_ts >= last_ts), 0) AS INTEGER) AS animated,
+		CAST(COALESCE(SUM(inactive_reason = '' AND animate_ts < last_ts), 0) AS INTEGER) AS pending,
+		CAST(COALESCE(SUM(cursor_manifest IS NOT NULL OR cursor_dep IS NOT NULL), 0) AS INTEGER) AS in_progress
+	FROM repos;
+	*/
+	repos, err := db.GetRepoStatus(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get repo status")
+	}
+	r.Repos = repoStatus{
+		Total:      repos.Total,
+		Inactive:   repos.Inactive,
+		Animated:   repos.Animated,
+		Pending:    repos.Pending,
+		InProgress: repos.InProgress,
+		Cursors:    []repoCursor{},
+	}
+
+	/* autoquery name: GetRepoCursors :many
+
+	SELECT owner_name, repo_name, cursor_manifest, cursor_dep, lease_ts
+	FROM repos
+	WHERE cursor_manifest IS NOT NULL OR cursor_dep IS NOT NULL
+	ORDER BY owner_name, repo_name;
+	*/
+	cursors, err := db.GetRepoCursors(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get repo cursors")
+	}
+	for _, cur := range cursors {
+		r.Repos.Cursors = append(r.Repos.Cursors, repoCursor{
+			Repo:     cur.OwnerName + "/" + cur.RepoName,
+			Manifest: cur.CursorManifest.String,
+			Dep:      cur.CursorDep.String,
+			Leased:   cur.LeaseTs > 0,
+		})
+	}
+
+	/* autoquery name: GetSponsorStatus :many
+
+	SELECT
+		d.sponsor_id,
+		COUNT(*) AS donations,
+		CAST(SUM(d.attempts > 0 AND d.failed_ts = 0 AND d.cancel_ts = 0) AS INTEGER) AS retrying,
+		CAST(SUM(d.failed_ts > 0 AND d.cancel_ts = 0) AS INTEGER) AS failed,
+		CAST(SUM(d.cancel_ts > 0) AS INTEGER) AS cancelled,
+		CAST((
+			SELECT COUNT(*)
+			FROM donation_events e
+			WHERE
+				e.sponsor_id = d.sponsor_id AND
+				e.outcome = 'success' AND
+				e.ts >= args.start_ts AND
+				e.ts < args.end_ts
+		) AS INTEGER) AS donated,
+		CAST((
+			SELECT COALESCE(SUM(e.amount), 0)
+			FROM donation_events e
+			WHERE
+				e.sponsor_id = d.sponsor_id AND
+				e.outcome = 'success' AND
+				e.ts >= args.start_ts AND
+				e.ts < args.end_ts
+		) AS INTEGER) AS donated_amount
+	FROM donations d, (
+		SELECT
+			CAST(sqlc.arg(start_ts) AS INTEGER) AS start_ts,
+			CAST(sqlc.arg(end_ts) AS INTEGER) AS end_ts
+	) args
+	GROUP BY d.sponsor_id
+	ORDER BY d.sponsor_id;
+	*/
+	sponsors, err := db.GetSponsorStatus(ctx, database.GetSponsorStatusParams{
+		StartTs: monthStart.Unix(),
+		EndTs:   monthStart.AddDate(0, 1, 0).Unix(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get sponsor status")
+	}
+
+	// The same query donate picks the donations to make with.
+	donables, err := db.GetDonables(ctx, database.GetDonablesParams{
+		IsRecurring: c.IsRecurring,
+		SinceTs:     donate.PeriodStart(now, c.Period).Unix(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get pending donations")
+	}
+	pending := map[string]int64{}
+	for _, d := range donables {
+		pending[d.SponsorID]++
+	}
+
+	r.Sponsors = []donationStatus{}
+	for _, s := range sponsors {
+		ds := donationStatus{
+			Sponsor:       s.SponsorID,
+			Total:         s.Donations,
+			Pending:       pending[s.SponsorID],
+			Retrying:      s.Retrying,
+			Failed:        s.Failed,
+			Cancelled:     s.Cancelled,
+			Donated:       s.Donated,
+			DonatedAmount: s.DonatedAmount,
+		}
+		r.Sponsors = append(r.Sponsors, ds)
+		r.Donations.add(ds)
+	}
+
+	/* autoquery name: GetFailingDonations :many
+
+	SELECT
+		d.sponsor_id,
+		d.recipient_id,
+		d.attempts,
+		CAST(d.failed_ts > 0 AS BOOLEAN) AS permanent,
+		CAST(CASE
+			WHEN d.failure != '' THEN d.failure
+			ELSE COALESCE((
+				SELECT e.error
+				FROM donation_events e
+				WHERE
+					e.sponsor_id = d.sponsor_id AND
+					e.recipient_id = d.recipient_id AND
+					e.outcome != 'success'
+				ORDER BY e.ts DESC, e.id DESC
+				LIMIT 1
+			), '')
+		END AS TEXT) AS reason
+	FROM donations d
+	WHERE
+		(d.failed_ts > 0 OR d.attempts > 0) AND
+		d.cancel_ts = 0
+	ORDER BY d.sponsor_id, d.recipient_id;
+	*/
+	failing, err := db.GetFailingDonations(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get failing donations")
+	}
+	r.Failing = []failingDonation{}
+	for _, f := range failing {
+		r.Failing = append(r.Failing, failingDonation{
+			Sponsor:   f.SponsorID,
+			Recipient: f.RecipientID,
+			Attempts:  f.Attempts,
+			Permanent: f.Permanent,
+			Reason:    f.Reason,
+		})
+	}
+
+	switch c.Format {
+	case "json":
+		return writeJSON(os.Stdout, r)
+	case "csv":
+		return writeCsv(os.Stdout, r)
+	}
+	return writeTable(os.Stdout, r)
+}
@@ -0,0 +1,187 @@
+package status
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// report is the status of the database.
+type report struct {
+	Month     string            `json:"month"`
+	Repos     repoStatus        `json:"repos"`
+	Donations donationStatus    `json:"donations"`
+	Failing   []failingDonation `json:"failing"`
+	Sponsors  []donationStatus  `json:"sponsors"`
+}
+
+type repoStatus struct {
+	Total      int64        `json:"total"`
+	Inactive   int64        `json:"inactive"`
+	Animated   int64        `json:"animated"`
+	Pending    int64        `json:"pending"`
+	InProgress int64        `json:"in_progress"`
+	Cursors    []repoCursor `json:"cursors"`
+}
+
+// repoCursor is where the animation of a repo left off.
+type repoCursor struct {
+	Repo     string `json:"repo"`
+	Manifest string `json:"manifest"`
+	Dep      string `json:"dep"`
+	Leased   bool   `json:"leased"`
+}
+
+// donationStatus counts the donations of a sponsor, or of all sponsors if
+// Sponsor is empty.
+type donationStatus struct {
+	Sponsor       string `json:"sponsor,omitempty"`
+	Total         int64  `json:"total"`
+	Pending       int64  `json:"pending"`
+	Retrying      int64  `json:"retrying"`
+	Failed        int64  `json:"failed"`
+	Cancelled     int64  `json:"cancelled"`
+	Donated       int64  `json:"donated"`
+	DonatedAmount int64  `json:"donated_amount"`
+}
+
+func (s *donationStatus) add(o donationStatus) {
+	s.Total += o.Total
+	s.Pending += o.Pending
+	s.Retrying += o.Retrying
+	s.Failed += o.Failed
+	s.Cancelled += o.Cancelled
+	s.Donated += o.Donated
+	s.DonatedAmount += o.DonatedAmount
+}
+
+func (s donationStatus) metrics() [][2]string {
+	return [][2]string{
+		{"total", strconv.FormatInt(s.Total, 10)},
+		{"pending", strconv.FormatInt(s.Pending, 10)},
+		{"retrying", strconv.FormatInt(s.Retrying, 10)},
+		{"failed", strconv.FormatInt(s.Failed, 10)},
+		{"cancelled", strconv.FormatInt(s.Cancelled, 10)},
+		{"donated", strconv.FormatInt(s.Donated, 10)},
+		{"donated_amount", strconv.FormatInt(s.DonatedAmount, 10)},
+	}
+}
+
+// failingDonation is a donation which failed the last time it was tried.
+type failingDonation struct {
+	Sponsor   string `json:"sponsor"`
+	Recipient string `json:"recipient"`
+	Attempts  int64  `json:"attempts"`
+	Permanent bool   `json:"permanent"`
+	Reason    string `json:"reason"`
+}
+
+// writeTable prints the report as aligned tables, one per section.
+func writeTable(w io.Writer, r report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "REPOS\tTOTAL\tINACTIVE\tANIMATED\tPENDING\tIN PROGRESS")
+	fmt.Fprintf(
+		tw,
+		"\t%d\t%d\t%d\t%d\t%d\n",
+		r.Repos.Total,
+		r.Repos.Inactive,
+		r.Repos.Animated,
+		r.Repos.Pending,
+		r.Repos.InProgress,
+	)
+	if len(r.Repos.Cursors) > 0 {
+		fmt.Fprintln(tw, "\nREPO\tCURSOR MANIFEST\tCURSOR DEP\tLEASED")
+		for _, c := range r.Repos.Cursors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", c.Repo, c.Manifest, c.Dep, c.Leased)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nSPONSOR\tTOTAL\tPENDING\tRETRYING\tFAILED\tCANCELLED\tDONATED %s\tAMOUNT %s\n", r.Month, r.Month)
+	for _, s := range append(r.Sponsors, r.Donations) {
+		name := s.Sponsor
+		if name == "" {
+			name = "TOTAL"
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			name,
+			s.Total,
+			s.Pending,
+			s.Retrying,
+			s.Failed,
+			s.Cancelled,
+			s.Donated,
+			s.DonatedAmount,
+		)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if len(r.Failing) == 0 {
+		return nil
+	}
+	// Reasons are long, so the failing donations aren't aligned with the
+	// tables above.
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nFAILING\tRECIPIENT\tATTEMPTS\tPERMANENT\tREASON")
+	for _, f := range r.Failing {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%s\n", f.Sponsor, f.Recipient, f.Attempts, f.Permanent, f.Reason)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCsv writes the report as one metric per row so every section fits
+// the same columns: the section, what the metric is about (a repo,
+// sponsor or failing donation), the metric and its value.
+func writeCsv(w io.Writer, r report) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"section", "name", "metric", "value"})
+	_ = cw.Write([]string{"month", "", "month", r.Month})
+
+	for _, m := range [][2]string{
+		{"total", strconv.FormatInt(r.Repos.Total, 10)},
+		{"inactive", strconv.FormatInt(r.Repos.Inactive, 10)},
+		{"animated", strconv.FormatInt(r.Repos.Animated, 10)},
+		{"pending", strconv.FormatInt(r.Repos.Pending, 10)},
+		{"in_progress", strconv.FormatInt(r.Repos.InProgress, 10)},
+	} {
+		_ = cw.Write([]string{"repos", "", m[0], m[1]})
+	}
+	for _, c := range r.Repos.Cursors {
+		_ = cw.Write([]string{"cursors", c.Repo, "manifest", c.Manifest})
+		_ = cw.Write([]string{"cursors", c.Repo, "dep", c.Dep})
+		_ = cw.Write([]string{"cursors", c.Repo, "leased", strconv.FormatBool(c.Leased)})
+	}
+
+	for _, m := range r.Donations.metrics() {
+		_ = cw.Write([]string{"donations", "", m[0], m[1]})
+	}
+	for _, s := range r.Sponsors {
+		for _, m := range s.metrics() {
+			_ = cw.Write([]string{"sponsors", s.Sponsor, m[0], m[1]})
+		}
+	}
+
+	for _, f := range r.Failing {
+		name := f.Sponsor + "/" + f.Recipient
+		_ = cw.Write([]string{"failing", name, "attempts", strconv.FormatInt(f.Attempts, 10)})
+		_ = cw.Write([]string{"failing", name, "permanent", strconv.FormatBool(f.Permanent)})
+		_ = cw.Write([]string{"failing", name, "reason", f.Reason})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
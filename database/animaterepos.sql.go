@@ -10,24 +10,44 @@ import (
 	"database/sql"
 )
 
-const getRepos = `-- name: GetRepos :one
+const claimRepo = `-- name: ClaimRepo :one
 
-SELECT owner_name, repo_name, cursor_manifest, cursor_dep
-FROM repos
-WHERE animate_ts < last_ts
-LIMIT 1
+UPDATE repos
+SET
+	lease_id = ?,
+	lease_ts = UNIXEPOCH(),
+	animate_start_ts = CASE
+		WHEN cursor_manifest IS NULL AND cursor_dep IS NULL THEN UNIXEPOCH()
+		ELSE animate_start_ts
+	END
+WHERE rowid = (
+	SELECT rowid
+	FROM repos
+	WHERE
+		animate_ts < last_ts AND
+		inactive_reason = '' AND
+		lease_ts < UNIXEPOCH() - CAST(? AS INTEGER)
+	ORDER BY animate_error != '', last_ts
+	LIMIT 1
+)
+RETURNING owner_name, repo_name, cursor_manifest, cursor_dep
 `
 
-type GetReposRow struct {
+type ClaimRepoParams struct {
+	LeaseID      string
+	LeaseTimeout int64
+}
+
+type ClaimRepoRow struct {
 	OwnerName      string
 	RepoName       string
 	CursorManifest sql.NullString
 	CursorDep      sql.NullString
 }
 
-func (q *Queries) GetRepos(ctx context.Context) (GetReposRow, error) {
-	row := q.db.QueryRowContext(ctx, getRepos)
-	var i GetReposRow
+func (q *Queries) ClaimRepo(ctx context.Context, arg ClaimRepoParams) (ClaimRepoRow, error) {
+	row := q.db.QueryRowContext(ctx, claimRepo, arg.LeaseID, arg.LeaseTimeout)
+	var i ClaimRepoRow
 	err := row.Scan(
 		&i.OwnerName,
 		&i.RepoName,
@@ -37,55 +57,236 @@ func (q *Queries) GetRepos(ctx context.Context) (GetReposRow, error) {
 	return i, err
 }
 
-const repoUpdateAnimateTs = `-- name: RepoUpdateAnimateTs :exec
+const deleteFundingTargets = `-- name: DeleteFundingTargets :exec
 
-UPDATE repos
-SET animate_ts = UNIXEPOCH()
+DELETE FROM funding_targets
 WHERE owner_name = ? AND repo_name = ?
 `
 
-type RepoUpdateAnimateTsParams struct {
+type DeleteFundingTargetsParams struct {
 	OwnerName string
 	RepoName  string
 }
 
-func (q *Queries) RepoUpdateAnimateTs(ctx context.Context, arg RepoUpdateAnimateTsParams) error {
-	_, err := q.db.ExecContext(ctx, repoUpdateAnimateTs, arg.OwnerName, arg.RepoName)
+func (q *Queries) DeleteFundingTargets(ctx context.Context, arg DeleteFundingTargetsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFundingTargets, arg.OwnerName, arg.RepoName)
 	return err
 }
 
-const repoUpdateCursorDep = `-- name: RepoUpdateCursorDep :exec
+const insertDonationDependent = `-- name: InsertDonationDependent :exec
 
-UPDATE repos
-SET cursor_dep = ?
-WHERE owner_name = ? AND repo_name = ?
+INSERT INTO donation_dependents (sponsor_id, recipient_id, repo_name, manifest, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (sponsor_id, recipient_id, repo_name, manifest)
+DO UPDATE SET last_ts = excluded.last_ts
+`
+
+type InsertDonationDependentParams struct {
+	SponsorID   string
+	RecipientID string
+	RepoName    string
+	Manifest    string
+}
+
+func (q *Queries) InsertDonationDependent(ctx context.Context, arg InsertDonationDependentParams) error {
+	_, err := q.db.ExecContext(ctx, insertDonationDependent,
+		arg.SponsorID,
+		arg.RecipientID,
+		arg.RepoName,
+		arg.Manifest,
+	)
+	return err
+}
+
+const insertFundingTarget = `-- name: InsertFundingTarget :exec
+
+INSERT INTO funding_targets (owner_name, repo_name, platform, handle, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (owner_name, repo_name, platform, handle)
+DO UPDATE SET last_ts = excluded.last_ts
 `
 
-type RepoUpdateCursorDepParams struct {
-	CursorDep sql.NullString
+type InsertFundingTargetParams struct {
 	OwnerName string
 	RepoName  string
+	Platform  string
+	Handle    string
 }
 
-func (q *Queries) RepoUpdateCursorDep(ctx context.Context, arg RepoUpdateCursorDepParams) error {
-	_, err := q.db.ExecContext(ctx, repoUpdateCursorDep, arg.CursorDep, arg.OwnerName, arg.RepoName)
+func (q *Queries) InsertFundingTarget(ctx context.Context, arg InsertFundingTargetParams) error {
+	_, err := q.db.ExecContext(ctx, insertFundingTarget,
+		arg.OwnerName,
+		arg.RepoName,
+		arg.Platform,
+		arg.Handle,
+	)
 	return err
 }
 
-const repoUpdateCursorManifest = `-- name: RepoUpdateCursorManifest :exec
+const releaseRepoLeases = `-- name: ReleaseRepoLeases :exec
 
 UPDATE repos
-SET cursor_manifest = ?
-WHERE owner_name = ? AND repo_name = ?
+SET lease_id = '', lease_ts = 0
+WHERE lease_id = ?
+`
+
+func (q *Queries) ReleaseRepoLeases(ctx context.Context, leaseID string) error {
+	_, err := q.db.ExecContext(ctx, releaseRepoLeases, leaseID)
+	return err
+}
+
+const repoSetAnimateError = `-- name: RepoSetAnimateError :exec
+
+UPDATE repos
+SET animate_error = ?
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?
+`
+
+type RepoSetAnimateErrorParams struct {
+	AnimateError string
+	OwnerName    string
+	RepoName     string
+	LeaseID      string
+}
+
+func (q *Queries) RepoSetAnimateError(ctx context.Context, arg RepoSetAnimateErrorParams) error {
+	_, err := q.db.ExecContext(ctx, repoSetAnimateError,
+		arg.AnimateError,
+		arg.OwnerName,
+		arg.RepoName,
+		arg.LeaseID,
+	)
+	return err
+}
+
+const repoUpdateAnimateTs = `-- name: RepoUpdateAnimateTs :execrows
+
+UPDATE repos
+SET
+	animate_ts = UNIXEPOCH(),
+	cursor_manifest = NULL,
+	cursor_dep = NULL,
+	lease_id = '',
+	lease_ts = 0,
+	animate_error = ''
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?
 `
 
-type RepoUpdateCursorManifestParams struct {
+type RepoUpdateAnimateTsParams struct {
+	OwnerName string
+	RepoName  string
+	LeaseID   string
+}
+
+func (q *Queries) RepoUpdateAnimateTs(ctx context.Context, arg RepoUpdateAnimateTsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, repoUpdateAnimateTs, arg.OwnerName, arg.RepoName, arg.LeaseID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const repoUpdateCursors = `-- name: RepoUpdateCursors :execrows
+
+UPDATE repos
+SET cursor_manifest = ?, cursor_dep = ?, lease_ts = UNIXEPOCH()
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?
+`
+
+type RepoUpdateCursorsParams struct {
 	CursorManifest sql.NullString
+	CursorDep      sql.NullString
 	OwnerName      string
 	RepoName       string
+	LeaseID        string
+}
+
+func (q *Queries) RepoUpdateCursors(ctx context.Context, arg RepoUpdateCursorsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, repoUpdateCursors,
+		arg.CursorManifest,
+		arg.CursorDep,
+		arg.OwnerName,
+		arg.RepoName,
+		arg.LeaseID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) RepoUpdateCursorManifest(ctx context.Context, arg RepoUpdateCursorManifestParams) error {
-	_, err := q.db.ExecContext(ctx, repoUpdateCursorManifest, arg.CursorManifest, arg.OwnerName, arg.RepoName)
+const upsertDependency = `-- name: UpsertDependency :exec
+
+INSERT INTO dependencies (
+	manifest_id, package_name, dep_owner_name, dep_repo_name, is_sponsorable,
+	package_manager, requirements, scope, excluded_by, last_ts, excluded_ts
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, UNIXEPOCH(), UNIXEPOCH())
+ON CONFLICT (manifest_id, package_name, dep_owner_name, dep_repo_name)
+DO UPDATE SET
+	is_sponsorable = excluded.is_sponsorable,
+	package_manager = excluded.package_manager,
+	requirements = excluded.requirements,
+	scope = excluded.scope,
+	excluded_by = excluded.excluded_by,
+	last_ts = excluded.last_ts,
+	excluded_ts = CASE
+		WHEN dependencies.excluded_by = '' THEN excluded.excluded_ts
+		ELSE dependencies.excluded_ts
+	END
+`
+
+type UpsertDependencyParams struct {
+	ManifestID     int64
+	PackageName    string
+	DepOwnerName   string
+	DepRepoName    string
+	IsSponsorable  bool
+	PackageManager string
+	Requirements   string
+	Scope          string
+	ExcludedBy     string
+}
+
+func (q *Queries) UpsertDependency(ctx context.Context, arg UpsertDependencyParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDependency,
+		arg.ManifestID,
+		arg.PackageName,
+		arg.DepOwnerName,
+		arg.DepRepoName,
+		arg.IsSponsorable,
+		arg.PackageManager,
+		arg.Requirements,
+		arg.Scope,
+		arg.ExcludedBy,
+	)
 	return err
 }
+
+const upsertManifest = `-- name: UpsertManifest :one
+
+INSERT INTO manifests (owner_name, repo_name, filename, blob_path, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (owner_name, repo_name, blob_path)
+DO UPDATE SET last_ts = excluded.last_ts
+RETURNING id
+`
+
+type UpsertManifestParams struct {
+	OwnerName string
+	RepoName  string
+	Filename  string
+	BlobPath  string
+}
+
+func (q *Queries) UpsertManifest(ctx context.Context, arg UpsertManifestParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, upsertManifest,
+		arg.OwnerName,
+		arg.RepoName,
+		arg.Filename,
+		arg.BlobPath,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
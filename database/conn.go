@@ -10,6 +10,7 @@ import (
 
 type DB struct {
 	*Queries
+	conn *sql.DB
 }
 
 // Open database connection and return the associated typed DB wrapper.
@@ -25,5 +26,25 @@ func Open(ctx context.Context, dsn string) (*DB, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open db")
 	}
-	return &DB{New(conn)}, nil
+	// SQLite only allows a single writer, serialise access from concurrent
+	// workers instead of failing with SQLITE_BUSY.
+	conn.SetMaxOpenConns(1)
+	return &DB{New(conn), conn}, nil
+}
+
+// Tx runs fn in a transaction which is committed if fn succeeds and rolled
+// back otherwise. fn must only use the given queries, the connection is
+// held by the transaction until it returns.
+func (d *DB) Tx(ctx context.Context, fn func(q *Queries) error) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	err = fn(d.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit transaction")
 }
@@ -9,20 +9,74 @@ import (
 	"context"
 )
 
-const reposInsert = `-- name: ReposInsert :exec
+const repoDeactivate = `-- name: RepoDeactivate :exec
 
-INSERT INTO repos (owner_name, repo_name, last_ts)
-VALUES (?, ?, UNIXEPOCH())
+UPDATE repos
+SET
+	inactive_reason = ?,
+	sync_ts = UNIXEPOCH(),
+	inactive_ts = CASE WHEN inactive_reason = '' THEN UNIXEPOCH() ELSE inactive_ts END
+WHERE owner_name = ? AND repo_name = ?
+`
+
+type RepoDeactivateParams struct {
+	InactiveReason string
+	OwnerName      string
+	RepoName       string
+}
+
+func (q *Queries) RepoDeactivate(ctx context.Context, arg RepoDeactivateParams) error {
+	_, err := q.db.ExecContext(ctx, repoDeactivate, arg.InactiveReason, arg.OwnerName, arg.RepoName)
+	return err
+}
+
+const reposDeactivateVanished = `-- name: ReposDeactivateVanished :execrows
+
+UPDATE repos
+SET inactive_reason = 'vanished', inactive_ts = UNIXEPOCH()
+WHERE
+	owner_name = ? COLLATE NOCASE AND
+	NOT is_local AND
+	sync_ts < ? AND
+	inactive_reason = ''
+`
+
+type ReposDeactivateVanishedParams struct {
+	OwnerName string
+	SyncTs    int64
+}
+
+func (q *Queries) ReposDeactivateVanished(ctx context.Context, arg ReposDeactivateVanishedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reposDeactivateVanished, arg.OwnerName, arg.SyncTs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const reposUpsert = `-- name: ReposUpsert :exec
+
+INSERT INTO repos (owner_name, repo_name, last_ts, pushed_ts, sync_ts)
+VALUES (?, ?, UNIXEPOCH(), ?, UNIXEPOCH())
 ON CONFLICT (owner_name, repo_name)
-DO NOTHING
+DO UPDATE SET
+	last_ts = CASE
+		WHEN excluded.pushed_ts > repos.animate_ts OR repos.inactive_reason != '' THEN excluded.last_ts
+		ELSE repos.last_ts
+	END,
+	pushed_ts = excluded.pushed_ts,
+	sync_ts = excluded.sync_ts,
+	is_local = FALSE,
+	inactive_reason = ''
 `
 
-type ReposInsertParams struct {
+type ReposUpsertParams struct {
 	OwnerName string
 	RepoName  string
+	PushedTs  int64
 }
 
-func (q *Queries) ReposInsert(ctx context.Context, arg ReposInsertParams) error {
-	_, err := q.db.ExecContext(ctx, reposInsert, arg.OwnerName, arg.RepoName)
+func (q *Queries) ReposUpsert(ctx context.Context, arg ReposUpsertParams) error {
+	_, err := q.db.ExecContext(ctx, reposUpsert, arg.OwnerName, arg.RepoName, arg.PushedTs)
 	return err
 }
@@ -7,25 +7,107 @@ package database
 
 import (
 	"context"
+	"database/sql"
 )
 
+const acquireLease = `-- name: AcquireLease :execrows
+
+INSERT INTO leases (name, holder, expires_ts)
+VALUES (?, ?, UNIXEPOCH() + CAST(? AS INTEGER))
+ON CONFLICT (name)
+DO UPDATE SET
+	holder = excluded.holder,
+	expires_ts = excluded.expires_ts
+WHERE leases.expires_ts < UNIXEPOCH() OR leases.holder = excluded.holder
+`
+
+type AcquireLeaseParams struct {
+	Name    string
+	Holder  string
+	Timeout int64
+}
+
+func (q *Queries) AcquireLease(ctx context.Context, arg AcquireLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acquireLease, arg.Name, arg.Holder, arg.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getDonables = `-- name: GetDonables :many
 
-SELECT id, sponsor_id, recipient_id
-FROM donations
+SELECT
+	d.id,
+	d.sponsor_id,
+	d.recipient_id,
+	d.target_amount,
+	CAST(COALESCE(d.is_recurring, args.is_recurring) AS BOOLEAN) AS is_recurring,
+	CAST(COALESCE(
+		d.privacy_level,
+		(SELECT sd.privacy_level FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+		'PUBLIC'
+	) AS TEXT) AS privacy_level,
+	CAST(COALESCE(
+		d.receive_emails,
+		(SELECT sd.receive_emails FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+		FALSE
+	) AS BOOLEAN) AS receive_emails,
+	CAST((
+		SELECT COUNT(DISTINCT dd.repo_name)
+		FROM donation_dependents dd
+		WHERE
+			dd.sponsor_id = d.sponsor_id AND
+			dd.recipient_id = d.recipient_id
+	) AS INTEGER) AS dependents,
+	d.unconfirmed_ts
+FROM donations d, (
+	SELECT
+		CAST(? AS BOOLEAN) AS is_recurring,
+		CAST(? AS INTEGER) AS since_ts
+) args
 WHERE
-	donate_ts < last_ts AND
-	donate_attempt_ts < UNIXEPOCH() - 3600
+	(
+		d.donate_ts < d.last_ts OR
+		(
+			NOT COALESCE(d.is_recurring, args.is_recurring) AND
+			d.donate_ts < args.since_ts
+		)
+	) AND
+	d.donate_attempt_ts + MIN(3600 << MIN(MAX(d.attempts - 1, 0), 10), 604800) < UNIXEPOCH() AND
+	d.failed_ts = 0 AND
+	d.cancel_ts = 0 AND
+	NOT EXISTS (
+		SELECT 1
+		FROM sponsorships s
+		WHERE
+			s.sponsor_id = d.sponsor_id AND
+			s.recipient_id = d.recipient_id COLLATE NOCASE AND
+			s.is_active AND
+			NOT s.is_one_time
+	)
+ORDER BY d.id
 `
 
+type GetDonablesParams struct {
+	IsRecurring bool
+	SinceTs     int64
+}
+
 type GetDonablesRow struct {
-	ID          int64
-	SponsorID   string
-	RecipientID string
+	ID            int64
+	SponsorID     string
+	RecipientID   string
+	TargetAmount  sql.NullInt64
+	IsRecurring   bool
+	PrivacyLevel  string
+	ReceiveEmails bool
+	Dependents    int64
+	UnconfirmedTs int64
 }
 
-func (q *Queries) GetDonables(ctx context.Context) ([]GetDonablesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getDonables)
+func (q *Queries) GetDonables(ctx context.Context, arg GetDonablesParams) ([]GetDonablesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDonables, arg.IsRecurring, arg.SinceTs)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +115,101 @@ func (q *Queries) GetDonables(ctx context.Context) ([]GetDonablesRow, error) {
 	var items []GetDonablesRow
 	for rows.Next() {
 		var i GetDonablesRow
-		if err := rows.Scan(&i.ID, &i.SponsorID, &i.RecipientID); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.SponsorID,
+			&i.RecipientID,
+			&i.TargetAmount,
+			&i.IsRecurring,
+			&i.PrivacyLevel,
+			&i.ReceiveEmails,
+			&i.Dependents,
+			&i.UnconfirmedTs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDonatedThisMonth = `-- name: GetDonatedThisMonth :many
+
+WITH
+	args AS (
+		SELECT
+			CAST(? AS INTEGER) AS month_start_ts,
+			CAST(? AS BOOLEAN) AS is_recurring
+	),
+	recurring AS (
+		SELECT s.sponsor_id, s.recipient_id, s.amount
+		FROM sponsorships s
+		WHERE s.is_active AND NOT s.is_one_time
+		UNION ALL
+		SELECT d.sponsor_id, d.recipient_id, d.amount
+		FROM donations d, args
+		WHERE
+			d.donate_ts > 0 AND
+			d.cancel_ts = 0 AND
+			COALESCE(d.is_recurring, args.is_recurring) AND
+			NOT EXISTS (
+				SELECT 1
+				FROM sponsorships s
+				WHERE
+					s.sponsor_id = d.sponsor_id AND
+					s.recipient_id = d.recipient_id COLLATE NOCASE
+			)
+	)
+SELECT sponsor_id, CAST(SUM(amount) AS INTEGER) AS total
+FROM (
+	SELECT sponsor_id, amount
+	FROM recurring
+	UNION ALL
+	SELECT e.sponsor_id, e.amount
+	FROM donation_events e, args
+	WHERE
+		e.outcome = 'success' AND
+		e.ts >= args.month_start_ts AND
+		NOT (
+			e.is_recurring AND
+			EXISTS (
+				SELECT 1
+				FROM recurring r
+				WHERE
+					r.sponsor_id = e.sponsor_id AND
+					r.recipient_id = e.recipient_id COLLATE NOCASE
+			)
+		)
+)
+GROUP BY sponsor_id
+`
+
+type GetDonatedThisMonthParams struct {
+	MonthStartTs int64
+	IsRecurring  bool
+}
+
+type GetDonatedThisMonthRow struct {
+	SponsorID string
+	Total     int64
+}
+
+func (q *Queries) GetDonatedThisMonth(ctx context.Context, arg GetDonatedThisMonthParams) ([]GetDonatedThisMonthRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDonatedThisMonth, arg.MonthStartTs, arg.IsRecurring)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDonatedThisMonthRow
+	for rows.Next() {
+		var i GetDonatedThisMonthRow
+		if err := rows.Scan(&i.SponsorID, &i.Total); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -47,10 +223,110 @@ func (q *Queries) GetDonables(ctx context.Context) ([]GetDonablesRow, error) {
 	return items, nil
 }
 
+const getDonationDependentRepos = `-- name: GetDonationDependentRepos :many
+
+SELECT DISTINCT repo_name
+FROM donation_dependents
+WHERE sponsor_id = ? AND recipient_id = ?
+ORDER BY repo_name
+`
+
+type GetDonationDependentReposParams struct {
+	SponsorID   string
+	RecipientID string
+}
+
+func (q *Queries) GetDonationDependentRepos(ctx context.Context, arg GetDonationDependentReposParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getDonationDependentRepos, arg.SponsorID, arg.RecipientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var repo_name string
+		if err := rows.Scan(&repo_name); err != nil {
+			return nil, err
+		}
+		items = append(items, repo_name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertDonationEvent = `-- name: InsertDonationEvent :exec
+
+INSERT INTO donation_events (ts, sponsor_id, recipient_id, amount, is_recurring, privacy_level, tier_id, tier_name, outcome, error)
+VALUES (UNIXEPOCH(), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertDonationEventParams struct {
+	SponsorID    string
+	RecipientID  string
+	Amount       int64
+	IsRecurring  bool
+	PrivacyLevel string
+	TierID       string
+	TierName     string
+	Outcome      string
+	Error        string
+}
+
+func (q *Queries) InsertDonationEvent(ctx context.Context, arg InsertDonationEventParams) error {
+	_, err := q.db.ExecContext(ctx, insertDonationEvent,
+		arg.SponsorID,
+		arg.RecipientID,
+		arg.Amount,
+		arg.IsRecurring,
+		arg.PrivacyLevel,
+		arg.TierID,
+		arg.TierName,
+		arg.Outcome,
+		arg.Error,
+	)
+	return err
+}
+
+const releaseLease = `-- name: ReleaseLease :exec
+
+DELETE FROM leases
+WHERE name = ? AND holder = ?
+`
+
+type ReleaseLeaseParams struct {
+	Name   string
+	Holder string
+}
+
+func (q *Queries) ReleaseLease(ctx context.Context, arg ReleaseLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseLease, arg.Name, arg.Holder)
+	return err
+}
+
+const resetFailedDonations = `-- name: ResetFailedDonations :execrows
+
+UPDATE donations
+SET failed_ts = 0, failure = '', attempts = 0, donate_attempt_ts = 0
+WHERE failed_ts > 0
+`
+
+func (q *Queries) ResetFailedDonations(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resetFailedDonations)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateDonationDonateAttemptTs = `-- name: UpdateDonationDonateAttemptTs :exec
 
 UPDATE donations
-SET donate_attempt_ts = UNIXEPOCH()
+SET donate_attempt_ts = UNIXEPOCH(), attempts = attempts + 1
 WHERE id = ?
 `
 
@@ -62,11 +338,50 @@ func (q *Queries) UpdateDonationDonateAttemptTs(ctx context.Context, id int64) e
 const updateDonationDonateTs = `-- name: UpdateDonationDonateTs :exec
 
 UPDATE donations
-SET donate_ts = UNIXEPOCH()
+SET donate_ts = UNIXEPOCH(), amount = ?, attempts = 0, unconfirmed_ts = 0
+WHERE id = ?
+`
+
+type UpdateDonationDonateTsParams struct {
+	Amount int64
+	ID     int64
+}
+
+func (q *Queries) UpdateDonationDonateTs(ctx context.Context, arg UpdateDonationDonateTsParams) error {
+	_, err := q.db.ExecContext(ctx, updateDonationDonateTs, arg.Amount, arg.ID)
+	return err
+}
+
+const updateDonationFailed = `-- name: UpdateDonationFailed :exec
+
+UPDATE donations
+SET
+	donate_attempt_ts = UNIXEPOCH(),
+	attempts = attempts + 1,
+	failed_ts = UNIXEPOCH(),
+	failure = ?,
+	unconfirmed_ts = 0
+WHERE id = ?
+`
+
+type UpdateDonationFailedParams struct {
+	Failure string
+	ID      int64
+}
+
+func (q *Queries) UpdateDonationFailed(ctx context.Context, arg UpdateDonationFailedParams) error {
+	_, err := q.db.ExecContext(ctx, updateDonationFailed, arg.Failure, arg.ID)
+	return err
+}
+
+const updateDonationUnconfirmedTs = `-- name: UpdateDonationUnconfirmedTs :exec
+
+UPDATE donations
+SET unconfirmed_ts = UNIXEPOCH()
 WHERE id = ?
 `
 
-func (q *Queries) UpdateDonationDonateTs(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, updateDonationDonateTs, id)
+func (q *Queries) UpdateDonationUnconfirmedTs(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, updateDonationUnconfirmedTs, id)
 	return err
 }
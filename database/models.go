@@ -8,6 +8,20 @@ import (
 	"database/sql"
 )
 
+type Dependency struct {
+	ManifestID     int64
+	PackageName    string
+	DepOwnerName   string
+	DepRepoName    string
+	IsSponsorable  bool
+	LastTs         int64
+	PackageManager string
+	Requirements   string
+	Scope          string
+	ExcludedBy     string
+	ExcludedTs     int64
+}
+
 type Donation struct {
 	ID              int64
 	SponsorID       string
@@ -15,6 +29,61 @@ type Donation struct {
 	LastTs          int64
 	DonateTs        int64
 	DonateAttemptTs int64
+	Amount          int64
+	CancelTs        int64
+	Attempts        int64
+	FailedTs        int64
+	Failure         string
+	PrivacyLevel    sql.NullString
+	ReceiveEmails   sql.NullBool
+	TargetAmount    sql.NullInt64
+	IsRecurring     sql.NullBool
+	UnconfirmedTs   int64
+}
+
+type DonationEvent struct {
+	ID           int64
+	Ts           int64
+	SponsorID    string
+	RecipientID  string
+	Amount       int64
+	IsRecurring  bool
+	PrivacyLevel string
+	Outcome      string
+	Error        string
+	TierID       string
+	TierName     string
+}
+
+type DonationDependent struct {
+	SponsorID   string
+	RecipientID string
+	RepoName    string
+	Manifest    string
+	LastTs      int64
+}
+
+type FundingTarget struct {
+	OwnerName string
+	RepoName  string
+	Platform  string
+	Handle    string
+	LastTs    int64
+}
+
+type Lease struct {
+	Name      string
+	Holder    string
+	ExpiresTs int64
+}
+
+type Manifest struct {
+	ID        int64
+	OwnerName string
+	RepoName  string
+	Filename  string
+	BlobPath  string
+	LastTs    int64
 }
 
 type Repo struct {
@@ -24,4 +93,50 @@ type Repo struct {
 	CursorManifest sql.NullString
 	CursorDep      sql.NullString
 	AnimateTs      int64
+	LeaseID        string
+	LeaseTs        int64
+	AnimateStartTs int64
+	PushedTs       int64
+	SyncTs         int64
+	InactiveReason string
+	InactiveTs     int64
+	AnimateError   string
+	IsLocal        bool
+}
+
+type SbomComponent struct {
+	SponsorID   string
+	RecipientID string
+	Sbom        string
+	Component   string
+	Purl        string
+	LastTs      int64
+}
+
+type Schedule struct {
+	Stage   string
+	StartTs int64
+	EndTs   int64
+	Error   string
+}
+
+type SponsorDefault struct {
+	SponsorID     string
+	PrivacyLevel  string
+	ReceiveEmails bool
+}
+
+type Sponsorship struct {
+	SponsorID    string
+	RecipientID  string
+	GhID         string
+	TierID       string
+	TierName     string
+	Amount       int64
+	IsOneTime    bool
+	PrivacyLevel string
+	CreatedTs    int64
+	IsActive     bool
+	SyncTs       int64
+	CancelTs     int64
 }
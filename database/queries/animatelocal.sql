@@ -0,0 +1,15 @@
+-- name: UpsertLocalRepo :exec
+
+INSERT INTO repos (owner_name, repo_name, last_ts, animate_ts, animate_start_ts, is_local)
+VALUES (?, ?, UNIXEPOCH(), UNIXEPOCH(), UNIXEPOCH(), TRUE)
+ON CONFLICT (owner_name, repo_name)
+DO UPDATE SET
+	last_ts = excluded.last_ts,
+	animate_ts = excluded.animate_ts,
+	animate_start_ts = excluded.animate_start_ts,
+	cursor_manifest = NULL,
+	cursor_dep = NULL,
+	lease_id = '',
+	lease_ts = 0,
+	inactive_reason = '';
+
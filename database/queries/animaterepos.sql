@@ -1,25 +1,99 @@
--- name: GetRepos :one
+-- name: ReleaseRepoLeases :exec
 
-SELECT owner_name, repo_name, cursor_manifest, cursor_dep
-FROM repos
-WHERE animate_ts < last_ts
-LIMIT 1;
+UPDATE repos
+SET lease_id = '', lease_ts = 0
+WHERE lease_id = ?;
 
--- name: RepoUpdateCursorDep :exec
+-- name: ClaimRepo :one
 
 UPDATE repos
-SET cursor_dep = ?
-WHERE owner_name = ? AND repo_name = ?;
+SET
+	lease_id = sqlc.arg(lease_id),
+	lease_ts = UNIXEPOCH(),
+	animate_start_ts = CASE
+		WHEN cursor_manifest IS NULL AND cursor_dep IS NULL THEN UNIXEPOCH()
+		ELSE animate_start_ts
+	END
+WHERE rowid = (
+	SELECT rowid
+	FROM repos
+	WHERE
+		animate_ts < last_ts AND
+		inactive_reason = '' AND
+		lease_ts < UNIXEPOCH() - CAST(sqlc.arg(lease_timeout) AS INTEGER)
+	ORDER BY animate_error != '', last_ts
+	LIMIT 1
+)
+RETURNING owner_name, repo_name, cursor_manifest, cursor_dep;
 
--- name: RepoUpdateCursorManifest :exec
+-- name: RepoSetAnimateError :exec
 
 UPDATE repos
-SET cursor_manifest = ?
-WHERE owner_name = ? AND repo_name = ?;
+SET animate_error = ?
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+
+-- name: UpsertManifest :one
+
+INSERT INTO manifests (owner_name, repo_name, filename, blob_path, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (owner_name, repo_name, blob_path)
+DO UPDATE SET last_ts = excluded.last_ts
+RETURNING id;
+
+-- name: UpsertDependency :exec
+
+INSERT INTO dependencies (
+	manifest_id, package_name, dep_owner_name, dep_repo_name, is_sponsorable,
+	package_manager, requirements, scope, excluded_by, last_ts, excluded_ts
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, UNIXEPOCH(), UNIXEPOCH())
+ON CONFLICT (manifest_id, package_name, dep_owner_name, dep_repo_name)
+DO UPDATE SET
+	is_sponsorable = excluded.is_sponsorable,
+	package_manager = excluded.package_manager,
+	requirements = excluded.requirements,
+	scope = excluded.scope,
+	excluded_by = excluded.excluded_by,
+	last_ts = excluded.last_ts,
+	excluded_ts = CASE
+		WHEN dependencies.excluded_by = '' THEN excluded.excluded_ts
+		ELSE dependencies.excluded_ts
+	END;
+
+-- name: InsertDonationDependent :exec
 
--- name: RepoUpdateAnimateTs :exec
+INSERT INTO donation_dependents (sponsor_id, recipient_id, repo_name, manifest, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (sponsor_id, recipient_id, repo_name, manifest)
+DO UPDATE SET last_ts = excluded.last_ts;
+
+-- name: RepoUpdateAnimateTs :execrows
+
+UPDATE repos
+SET
+	animate_ts = UNIXEPOCH(),
+	cursor_manifest = NULL,
+	cursor_dep = NULL,
+	lease_id = '',
+	lease_ts = 0,
+	animate_error = ''
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+
+-- name: RepoUpdateCursors :execrows
 
 UPDATE repos
-SET animate_ts = UNIXEPOCH()
+SET cursor_manifest = ?, cursor_dep = ?, lease_ts = UNIXEPOCH()
+WHERE owner_name = ? AND repo_name = ? AND lease_id = ?;
+
+-- name: DeleteFundingTargets :exec
+
+DELETE FROM funding_targets
 WHERE owner_name = ? AND repo_name = ?;
 
+-- name: InsertFundingTarget :exec
+
+INSERT INTO funding_targets (owner_name, repo_name, platform, handle, last_ts)
+VALUES (?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (owner_name, repo_name, platform, handle)
+DO UPDATE SET last_ts = excluded.last_ts;
+
@@ -1,7 +1,34 @@
--- name: ReposInsert :exec
+-- name: RepoDeactivate :exec
 
-INSERT INTO repos (owner_name, repo_name, last_ts)
-VALUES (?, ?, UNIXEPOCH())
+UPDATE repos
+SET
+	inactive_reason = ?,
+	sync_ts = UNIXEPOCH(),
+	inactive_ts = CASE WHEN inactive_reason = '' THEN UNIXEPOCH() ELSE inactive_ts END
+WHERE owner_name = ? AND repo_name = ?;
+
+-- name: ReposUpsert :exec
+
+INSERT INTO repos (owner_name, repo_name, last_ts, pushed_ts, sync_ts)
+VALUES (?, ?, UNIXEPOCH(), ?, UNIXEPOCH())
 ON CONFLICT (owner_name, repo_name)
-DO NOTHING;
+DO UPDATE SET
+	last_ts = CASE
+		WHEN excluded.pushed_ts > repos.animate_ts OR repos.inactive_reason != '' THEN excluded.last_ts
+		ELSE repos.last_ts
+	END,
+	pushed_ts = excluded.pushed_ts,
+	sync_ts = excluded.sync_ts,
+	is_local = FALSE,
+	inactive_reason = '';
+
+-- name: ReposDeactivateVanished :execrows
+
+UPDATE repos
+SET inactive_reason = 'vanished', inactive_ts = UNIXEPOCH()
+WHERE
+	owner_name = ? COLLATE NOCASE AND
+	NOT is_local AND
+	sync_ts < ? AND
+	inactive_reason = '';
 
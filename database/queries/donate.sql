@@ -1,20 +1,165 @@
+-- name: GetDonatedThisMonth :many
+
+WITH
+	args AS (
+		SELECT
+			CAST(sqlc.arg(month_start_ts) AS INTEGER) AS month_start_ts,
+			CAST(sqlc.arg(is_recurring) AS BOOLEAN) AS is_recurring
+	),
+	recurring AS (
+		SELECT s.sponsor_id, s.recipient_id, s.amount
+		FROM sponsorships s
+		WHERE s.is_active AND NOT s.is_one_time
+		UNION ALL
+		SELECT d.sponsor_id, d.recipient_id, d.amount
+		FROM donations d, args
+		WHERE
+			d.donate_ts > 0 AND
+			d.cancel_ts = 0 AND
+			COALESCE(d.is_recurring, args.is_recurring) AND
+			NOT EXISTS (
+				SELECT 1
+				FROM sponsorships s
+				WHERE
+					s.sponsor_id = d.sponsor_id AND
+					s.recipient_id = d.recipient_id COLLATE NOCASE
+			)
+	)
+SELECT sponsor_id, CAST(SUM(amount) AS INTEGER) AS total
+FROM (
+	SELECT sponsor_id, amount
+	FROM recurring
+	UNION ALL
+	SELECT e.sponsor_id, e.amount
+	FROM donation_events e, args
+	WHERE
+		e.outcome = 'success' AND
+		e.ts >= args.month_start_ts AND
+		NOT (
+			e.is_recurring AND
+			EXISTS (
+				SELECT 1
+				FROM recurring r
+				WHERE
+					r.sponsor_id = e.sponsor_id AND
+					r.recipient_id = e.recipient_id COLLATE NOCASE
+			)
+		)
+)
+GROUP BY sponsor_id;
+
+-- name: ResetFailedDonations :execrows
+
+UPDATE donations
+SET failed_ts = 0, failure = '', attempts = 0, donate_attempt_ts = 0
+WHERE failed_ts > 0;
+
 -- name: GetDonables :many
 
-SELECT id, sponsor_id, recipient_id
-FROM donations
+SELECT
+	d.id,
+	d.sponsor_id,
+	d.recipient_id,
+	d.target_amount,
+	CAST(COALESCE(d.is_recurring, args.is_recurring) AS BOOLEAN) AS is_recurring,
+	CAST(COALESCE(
+		d.privacy_level,
+		(SELECT sd.privacy_level FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+		'PUBLIC'
+	) AS TEXT) AS privacy_level,
+	CAST(COALESCE(
+		d.receive_emails,
+		(SELECT sd.receive_emails FROM sponsor_defaults sd WHERE sd.sponsor_id = d.sponsor_id),
+		FALSE
+	) AS BOOLEAN) AS receive_emails,
+	CAST((
+		SELECT COUNT(DISTINCT dd.repo_name)
+		FROM donation_dependents dd
+		WHERE
+			dd.sponsor_id = d.sponsor_id AND
+			dd.recipient_id = d.recipient_id
+	) AS INTEGER) AS dependents,
+	d.unconfirmed_ts
+FROM donations d, (
+	SELECT
+		CAST(sqlc.arg(is_recurring) AS BOOLEAN) AS is_recurring,
+		CAST(sqlc.arg(since_ts) AS INTEGER) AS since_ts
+) args
 WHERE
-	donate_ts < last_ts AND
-	donate_attempt_ts < UNIXEPOCH() - 3600;
+	(
+		d.donate_ts < d.last_ts OR
+		(
+			NOT COALESCE(d.is_recurring, args.is_recurring) AND
+			d.donate_ts < args.since_ts
+		)
+	) AND
+	d.donate_attempt_ts + MIN(3600 << MIN(MAX(d.attempts - 1, 0), 10), 604800) < UNIXEPOCH() AND
+	d.failed_ts = 0 AND
+	d.cancel_ts = 0 AND
+	NOT EXISTS (
+		SELECT 1
+		FROM sponsorships s
+		WHERE
+			s.sponsor_id = d.sponsor_id AND
+			s.recipient_id = d.recipient_id COLLATE NOCASE AND
+			s.is_active AND
+			NOT s.is_one_time
+	)
+ORDER BY d.id;
+
+-- name: UpdateDonationUnconfirmedTs :exec
+
+UPDATE donations
+SET unconfirmed_ts = UNIXEPOCH()
+WHERE id = ?;
+
+-- name: InsertDonationEvent :exec
+
+INSERT INTO donation_events (ts, sponsor_id, recipient_id, amount, is_recurring, privacy_level, tier_id, tier_name, outcome, error)
+VALUES (UNIXEPOCH(), ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: UpdateDonationFailed :exec
+
+UPDATE donations
+SET
+	donate_attempt_ts = UNIXEPOCH(),
+	attempts = attempts + 1,
+	failed_ts = UNIXEPOCH(),
+	failure = ?,
+	unconfirmed_ts = 0
+WHERE id = ?;
 
 -- name: UpdateDonationDonateAttemptTs :exec
 
 UPDATE donations
-SET donate_attempt_ts = UNIXEPOCH()
+SET donate_attempt_ts = UNIXEPOCH(), attempts = attempts + 1
 WHERE id = ?;
 
 -- name: UpdateDonationDonateTs :exec
 
 UPDATE donations
-SET donate_ts = UNIXEPOCH()
+SET donate_ts = UNIXEPOCH(), amount = ?, attempts = 0, unconfirmed_ts = 0
 WHERE id = ?;
 
+-- name: GetDonationDependentRepos :many
+
+SELECT DISTINCT repo_name
+FROM donation_dependents
+WHERE sponsor_id = ? AND recipient_id = ?
+ORDER BY repo_name;
+
+-- name: AcquireLease :execrows
+
+INSERT INTO leases (name, holder, expires_ts)
+VALUES (?, ?, UNIXEPOCH() + CAST(sqlc.arg(timeout) AS INTEGER))
+ON CONFLICT (name)
+DO UPDATE SET
+	holder = excluded.holder,
+	expires_ts = excluded.expires_ts
+WHERE leases.expires_ts < UNIXEPOCH() OR leases.holder = excluded.holder;
+
+-- name: ReleaseLease :exec
+
+DELETE FROM leases
+WHERE name = ? AND holder = ?;
+
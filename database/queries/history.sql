@@ -0,0 +1,15 @@
+-- name: GetDonationEvents :many
+
+SELECT e.ts, e.sponsor_id, e.recipient_id, e.amount, e.tier_name, e.is_recurring, e.privacy_level, e.outcome, e.error
+FROM donation_events e, (
+	SELECT
+		CAST(sqlc.arg(month) AS TEXT) AS month,
+		CAST(sqlc.arg(sponsor_id) AS TEXT) AS sponsor_id,
+		CAST(sqlc.arg(recipient_id) AS TEXT) AS recipient_id
+) f
+WHERE
+	(f.month = '' OR STRFTIME('%Y-%m', e.ts, 'unixepoch') = f.month) AND
+	(f.sponsor_id = '' OR e.sponsor_id = f.sponsor_id) AND
+	(f.recipient_id = '' OR e.recipient_id = f.recipient_id)
+ORDER BY e.ts, e.id;
+
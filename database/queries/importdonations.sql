@@ -0,0 +1,19 @@
+-- name: InsertImportedDonation :execrows
+
+INSERT INTO donations (sponsor_id, recipient_id, last_ts, target_amount, is_recurring, privacy_level, receive_emails)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (sponsor_id, recipient_id)
+DO NOTHING;
+
+-- name: UpdateImportedDonation :exec
+
+UPDATE donations
+SET
+	last_ts = CASE WHEN cancel_ts > 0 THEN UNIXEPOCH() ELSE last_ts END,
+	cancel_ts = 0,
+	target_amount = COALESCE(?, target_amount),
+	is_recurring = COALESCE(?, is_recurring),
+	privacy_level = COALESCE(?, privacy_level),
+	receive_emails = COALESCE(?, receive_emails)
+WHERE sponsor_id = ? AND recipient_id = ?;
+
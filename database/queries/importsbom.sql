@@ -0,0 +1,7 @@
+-- name: UpsertSbomComponent :exec
+
+INSERT INTO sbom_components (sponsor_id, recipient_id, sbom, component, purl, last_ts)
+VALUES (?, ?, ?, ?, ?, UNIXEPOCH())
+ON CONFLICT (sponsor_id, recipient_id, sbom, component)
+DO UPDATE SET purl = excluded.purl, last_ts = excluded.last_ts;
+
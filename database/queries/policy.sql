@@ -0,0 +1,6 @@
+-- name: GetRecipients :many
+
+SELECT DISTINCT recipient_id
+FROM donations
+ORDER BY recipient_id;
+
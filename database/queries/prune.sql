@@ -0,0 +1,61 @@
+-- name: GetPrunable :many
+
+SELECT
+	s.sponsor_id,
+	s.recipient_id,
+	s.tier_name,
+	s.amount,
+	CAST(MAX(CASE
+		WHEN d.excluded_by != '' THEN d.excluded_ts
+		WHEN
+			r.inactive_reason != '' AND
+			(d.last_ts >= r.animate_start_ts OR r.animate_ts < r.animate_start_ts)
+		THEN MAX(d.last_ts, r.inactive_ts)
+		ELSE d.last_ts
+	END) AS INTEGER) AS last_seen_ts
+FROM sponsorships s
+JOIN donations dn ON
+	dn.sponsor_id = s.sponsor_id AND
+	dn.recipient_id = s.recipient_id COLLATE NOCASE
+JOIN manifests m ON m.owner_name = s.sponsor_id
+JOIN dependencies d ON
+	d.manifest_id = m.id AND
+	d.dep_owner_name = s.recipient_id AND
+	d.is_sponsorable
+LEFT JOIN repos r ON
+	r.owner_name = m.owner_name AND
+	r.repo_name = m.repo_name
+WHERE
+	s.is_active AND
+	NOT s.is_one_time AND
+	NOT EXISTS (
+		SELECT 1
+		FROM dependencies cd
+		JOIN manifests cm ON cm.id = cd.manifest_id
+		JOIN repos r ON
+			r.owner_name = cm.owner_name AND
+			r.repo_name = cm.repo_name AND
+			r.inactive_reason = ''
+		WHERE
+			cm.owner_name = s.sponsor_id AND
+			cd.dep_owner_name = s.recipient_id AND
+			cd.is_sponsorable AND
+			cd.excluded_by = '' AND
+			(cd.last_ts >= r.animate_start_ts OR r.animate_ts < r.animate_start_ts)
+	)
+GROUP BY s.sponsor_id, s.recipient_id, s.tier_name, s.amount
+HAVING last_seen_ts < UNIXEPOCH() - CAST(sqlc.arg(grace_period) AS INTEGER)
+ORDER BY s.sponsor_id, s.recipient_id;
+
+-- name: CancelSponsorship :exec
+
+UPDATE sponsorships
+SET is_active = FALSE, cancel_ts = UNIXEPOCH()
+WHERE sponsor_id = ? AND recipient_id = ?;
+
+-- name: CancelDonation :exec
+
+UPDATE donations
+SET cancel_ts = UNIXEPOCH()
+WHERE sponsor_id = ? AND recipient_id = ? COLLATE NOCASE;
+
@@ -3,4 +3,5 @@
 INSERT INTO donations (sponsor_id, recipient_id, last_ts)
 VALUES (?, ?, ?)
 ON CONFLICT (sponsor_id, recipient_id)
-DO NOTHING;
+DO UPDATE SET cancel_ts = 0, last_ts = excluded.last_ts
+WHERE donations.cancel_ts > 0;
@@ -0,0 +1,75 @@
+-- name: GetSponsors :many
+
+SELECT DISTINCT sponsor_id
+FROM donations
+ORDER BY sponsor_id;
+
+-- name: MarkDonationsSponsored :execrows
+
+UPDATE donations
+SET
+	donate_ts = UNIXEPOCH(),
+	amount = (
+		SELECT s.amount
+		FROM sponsorships s
+		WHERE
+			s.sponsor_id = donations.sponsor_id AND
+			s.recipient_id = donations.recipient_id COLLATE NOCASE
+	)
+WHERE
+	donate_ts < last_ts AND
+	EXISTS (
+		SELECT 1
+		FROM sponsorships s
+		WHERE
+			s.sponsor_id = donations.sponsor_id AND
+			s.recipient_id = donations.recipient_id COLLATE NOCASE AND
+			s.is_active AND
+			(NOT s.is_one_time OR s.created_ts >= sqlc.arg(since_ts))
+	);
+
+-- name: GetUnknownSponsorships :many
+
+SELECT s.sponsor_id, s.recipient_id, s.tier_name, s.amount, s.is_one_time, s.created_ts
+FROM sponsorships s
+LEFT JOIN donations d ON
+	d.sponsor_id = s.sponsor_id AND
+	d.recipient_id = s.recipient_id COLLATE NOCASE
+WHERE s.is_active AND d.id IS NULL
+ORDER BY s.sponsor_id, s.recipient_id;
+
+-- name: UpsertSponsorship :exec
+
+INSERT INTO sponsorships (
+	sponsor_id,
+	recipient_id,
+	gh_id,
+	tier_id,
+	tier_name,
+	amount,
+	is_one_time,
+	privacy_level,
+	created_ts,
+	is_active,
+	sync_ts
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?)
+ON CONFLICT (sponsor_id, recipient_id)
+DO UPDATE SET
+	gh_id = excluded.gh_id,
+	tier_id = excluded.tier_id,
+	tier_name = excluded.tier_name,
+	amount = excluded.amount,
+	is_one_time = excluded.is_one_time,
+	privacy_level = excluded.privacy_level,
+	created_ts = excluded.created_ts,
+	is_active = TRUE,
+	cancel_ts = 0,
+	sync_ts = excluded.sync_ts;
+
+-- name: DeactivateStaleSponsorships :exec
+
+UPDATE sponsorships
+SET is_active = FALSE
+WHERE sponsor_id = ? AND sync_ts < ?;
+
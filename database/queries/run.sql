@@ -0,0 +1,18 @@
+-- name: GetSchedule :many
+
+SELECT stage, start_ts, end_ts, error
+FROM schedule;
+
+-- name: StartStage :exec
+
+INSERT INTO schedule (stage, start_ts)
+VALUES (?, UNIXEPOCH())
+ON CONFLICT (stage)
+DO UPDATE SET start_ts = excluded.start_ts, end_ts = 0;
+
+-- name: EndStage :exec
+
+UPDATE schedule
+SET end_ts = UNIXEPOCH(), error = ?
+WHERE stage = ?;
+
@@ -0,0 +1,21 @@
+-- name: InsertSponsorDefaults :exec
+
+INSERT INTO sponsor_defaults (sponsor_id, privacy_level, receive_emails)
+VALUES (?, 'PUBLIC', FALSE)
+ON CONFLICT (sponsor_id)
+DO NOTHING;
+
+-- name: UpdateSponsorDefaults :exec
+
+UPDATE sponsor_defaults
+SET
+	privacy_level = COALESCE(?, privacy_level),
+	receive_emails = COALESCE(?, receive_emails)
+WHERE sponsor_id = ?;
+
+-- name: GetSponsorDefaults :many
+
+SELECT sponsor_id, privacy_level, receive_emails
+FROM sponsor_defaults
+ORDER BY sponsor_id;
+
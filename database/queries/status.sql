@@ -0,0 +1,77 @@
+-- name: GetRepoStatus :one
+
+SELECT
+	COUNT(*) AS total,
+	CAST(COALESCE(SUM(inactive_reason != ''), 0) AS INTEGER) AS inactive,
+	CAST(COALESCE(SUM(inactive_reason = '' AND animate_ts >= last_ts), 0) AS INTEGER) AS animated,
+	CAST(COALESCE(SUM(inactive_reason = '' AND animate_ts < last_ts), 0) AS INTEGER) AS pending,
+	CAST(COALESCE(SUM(cursor_manifest IS NOT NULL OR cursor_dep IS NOT NULL), 0) AS INTEGER) AS in_progress
+FROM repos;
+
+-- name: GetRepoCursors :many
+
+SELECT owner_name, repo_name, cursor_manifest, cursor_dep, lease_ts
+FROM repos
+WHERE cursor_manifest IS NOT NULL OR cursor_dep IS NOT NULL
+ORDER BY owner_name, repo_name;
+
+-- name: GetSponsorStatus :many
+
+SELECT
+	d.sponsor_id,
+	COUNT(*) AS donations,
+	CAST(SUM(d.attempts > 0 AND d.failed_ts = 0 AND d.cancel_ts = 0) AS INTEGER) AS retrying,
+	CAST(SUM(d.failed_ts > 0 AND d.cancel_ts = 0) AS INTEGER) AS failed,
+	CAST(SUM(d.cancel_ts > 0) AS INTEGER) AS cancelled,
+	CAST((
+		SELECT COUNT(*)
+		FROM donation_events e
+		WHERE
+			e.sponsor_id = d.sponsor_id AND
+			e.outcome = 'success' AND
+			e.ts >= args.start_ts AND
+			e.ts < args.end_ts
+	) AS INTEGER) AS donated,
+	CAST((
+		SELECT COALESCE(SUM(e.amount), 0)
+		FROM donation_events e
+		WHERE
+			e.sponsor_id = d.sponsor_id AND
+			e.outcome = 'success' AND
+			e.ts >= args.start_ts AND
+			e.ts < args.end_ts
+	) AS INTEGER) AS donated_amount
+FROM donations d, (
+	SELECT
+		CAST(sqlc.arg(start_ts) AS INTEGER) AS start_ts,
+		CAST(sqlc.arg(end_ts) AS INTEGER) AS end_ts
+) args
+GROUP BY d.sponsor_id
+ORDER BY d.sponsor_id;
+
+-- name: GetFailingDonations :many
+
+SELECT
+	d.sponsor_id,
+	d.recipient_id,
+	d.attempts,
+	CAST(d.failed_ts > 0 AS BOOLEAN) AS permanent,
+	CAST(CASE
+		WHEN d.failure != '' THEN d.failure
+		ELSE COALESCE((
+			SELECT e.error
+			FROM donation_events e
+			WHERE
+				e.sponsor_id = d.sponsor_id AND
+				e.recipient_id = d.recipient_id AND
+				e.outcome != 'success'
+			ORDER BY e.ts DESC, e.id DESC
+			LIMIT 1
+		), '')
+	END AS TEXT) AS reason
+FROM donations d
+WHERE
+	(d.failed_ts > 0 OR d.attempts > 0) AND
+	d.cancel_ts = 0
+ORDER BY d.sponsor_id, d.recipient_id;
+
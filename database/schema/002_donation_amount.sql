@@ -0,0 +1,3 @@
+-- +goose Up
+
+ALTER TABLE donations ADD COLUMN amount INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+CREATE TABLE donation_dependents (
+  sponsor_id TEXT NOT NULL,
+  recipient_id TEXT NOT NULL,
+  repo_name TEXT NOT NULL,
+  manifest TEXT NOT NULL,
+  last_ts INTEGER NOT NULL,
+  UNIQUE (sponsor_id, recipient_id, repo_name, manifest)
+);
@@ -0,0 +1,23 @@
+-- +goose Up
+
+CREATE TABLE manifests (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  owner_name TEXT NOT NULL,
+  repo_name TEXT NOT NULL,
+  filename TEXT NOT NULL,
+  blob_path TEXT NOT NULL,
+  last_ts INTEGER NOT NULL,
+  UNIQUE (owner_name, repo_name, blob_path)
+);
+
+CREATE TABLE dependencies (
+  manifest_id INTEGER NOT NULL REFERENCES manifests (id),
+  package_name TEXT NOT NULL,
+  dep_owner_name TEXT NOT NULL,
+  dep_repo_name TEXT NOT NULL,
+  is_sponsorable BOOLEAN NOT NULL,
+  last_ts INTEGER NOT NULL,
+  UNIQUE (manifest_id, package_name, dep_owner_name, dep_repo_name)
+);
+
+CREATE INDEX dependencies_dep_owner_name ON dependencies (dep_owner_name);
@@ -0,0 +1,4 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN lease_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE repos ADD COLUMN lease_ts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+CREATE TABLE sponsorships (
+  sponsor_id TEXT NOT NULL,
+  recipient_id TEXT NOT NULL,
+  gh_id TEXT NOT NULL,
+  tier_id TEXT NOT NULL,
+  tier_name TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  is_one_time BOOLEAN NOT NULL,
+  privacy_level TEXT NOT NULL,
+  created_ts INTEGER NOT NULL,
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  sync_ts INTEGER NOT NULL,
+  UNIQUE (sponsor_id, recipient_id)
+);
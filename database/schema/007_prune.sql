@@ -0,0 +1,5 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN animate_start_ts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sponsorships ADD COLUMN cancel_ts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE donations ADD COLUMN cancel_ts INTEGER NOT NULL DEFAULT 0;
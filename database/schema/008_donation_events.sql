@@ -0,0 +1,29 @@
+-- +goose Up
+
+CREATE TABLE donation_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  ts INTEGER NOT NULL,
+  sponsor_id TEXT NOT NULL,
+  recipient_id TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  is_recurring BOOLEAN NOT NULL,
+  privacy_level TEXT NOT NULL,
+  outcome TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX donation_events_ts ON donation_events (ts);
+
+-- +goose StatementBegin
+CREATE TRIGGER donation_events_no_update BEFORE UPDATE ON donation_events
+BEGIN
+  SELECT RAISE(ABORT, 'donation_events is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER donation_events_no_delete BEFORE DELETE ON donation_events
+BEGIN
+  SELECT RAISE(ABORT, 'donation_events is append-only');
+END;
+-- +goose StatementEnd
@@ -0,0 +1,5 @@
+-- +goose Up
+
+ALTER TABLE donations ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE donations ADD COLUMN failed_ts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE donations ADD COLUMN failure TEXT NOT NULL DEFAULT '';
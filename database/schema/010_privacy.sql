@@ -0,0 +1,10 @@
+-- +goose Up
+
+ALTER TABLE donations ADD COLUMN privacy_level TEXT;
+ALTER TABLE donations ADD COLUMN receive_emails BOOLEAN;
+
+CREATE TABLE sponsor_defaults (
+  sponsor_id TEXT NOT NULL PRIMARY KEY,
+  privacy_level TEXT NOT NULL DEFAULT 'PUBLIC',
+  receive_emails BOOLEAN NOT NULL DEFAULT FALSE
+);
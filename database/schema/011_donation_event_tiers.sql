@@ -0,0 +1,4 @@
+-- +goose Up
+
+ALTER TABLE donation_events ADD COLUMN tier_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE donation_events ADD COLUMN tier_name TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- +goose Up
+
+ALTER TABLE donations ADD COLUMN target_amount INTEGER;
+ALTER TABLE donations ADD COLUMN is_recurring BOOLEAN;
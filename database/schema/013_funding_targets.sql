@@ -0,0 +1,10 @@
+-- +goose Up
+
+CREATE TABLE funding_targets (
+  owner_name TEXT NOT NULL,
+  repo_name TEXT NOT NULL,
+  platform TEXT NOT NULL,
+  handle TEXT NOT NULL,
+  last_ts INTEGER NOT NULL,
+  UNIQUE (owner_name, repo_name, platform, handle)
+);
@@ -0,0 +1,11 @@
+-- +goose Up
+
+CREATE TABLE sbom_components (
+  sponsor_id TEXT NOT NULL,
+  recipient_id TEXT NOT NULL,
+  sbom TEXT NOT NULL,
+  component TEXT NOT NULL,
+  purl TEXT NOT NULL,
+  last_ts INTEGER NOT NULL,
+  UNIQUE (sponsor_id, recipient_id, sbom, component)
+);
@@ -0,0 +1,6 @@
+-- +goose Up
+
+ALTER TABLE dependencies ADD COLUMN package_manager TEXT NOT NULL DEFAULT '';
+ALTER TABLE dependencies ADD COLUMN requirements TEXT NOT NULL DEFAULT '';
+ALTER TABLE dependencies ADD COLUMN scope TEXT NOT NULL DEFAULT '';
+ALTER TABLE dependencies ADD COLUMN excluded_by TEXT NOT NULL DEFAULT '';
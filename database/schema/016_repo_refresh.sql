@@ -0,0 +1,7 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN pushed_ts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE repos ADD COLUMN sync_ts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE repos ADD COLUMN inactive_reason TEXT NOT NULL DEFAULT '';
+
+UPDATE repos SET sync_ts = last_ts;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+CREATE TABLE leases (
+  name TEXT NOT NULL PRIMARY KEY,
+  holder TEXT NOT NULL,
+  expires_ts INTEGER NOT NULL
+);
+
+CREATE TABLE schedule (
+  stage TEXT NOT NULL PRIMARY KEY,
+  start_ts INTEGER NOT NULL DEFAULT 0,
+  end_ts INTEGER NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,4 @@
+-- +goose Up
+
+ALTER TABLE dependencies ADD COLUMN excluded_ts INTEGER NOT NULL DEFAULT 0;
+UPDATE dependencies SET excluded_ts = last_ts WHERE excluded_by != '';
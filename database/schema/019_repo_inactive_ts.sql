@@ -0,0 +1,4 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN inactive_ts INTEGER NOT NULL DEFAULT 0;
+UPDATE repos SET inactive_ts = sync_ts WHERE inactive_reason != '';
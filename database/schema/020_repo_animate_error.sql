@@ -0,0 +1,3 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN animate_error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- +goose Up
+
+ALTER TABLE repos ADD COLUMN is_local BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE repos SET is_local = TRUE WHERE sync_ts = 0;
@@ -0,0 +1,3 @@
+-- +goose Up
+
+ALTER TABLE donations ADD COLUMN unconfirmed_ts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// OpenTest opens a migrated database in a temporary directory for tests.
+// It also returns a second connection to it for inspecting and seeding
+// tables the generated queries don't cover.
+func OpenTest(t testing.TB) (*DB, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "db.sql")
+	db, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.conn.Close() })
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return db, conn
+}
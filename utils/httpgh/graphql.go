@@ -0,0 +1,91 @@
+package httpgh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// GraphQLError is an entry of the "errors" array of a GitHub GraphQL
+// response. Type is eg. NOT_FOUND, FORBIDDEN or UNPROCESSABLE.
+type GraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// GraphQLErrors collects the errors of the GraphQL responses to requests
+// made with the context returned by WithGraphQLErrors. The graphql client
+// only surfaces the first message, this keeps the error types as well.
+type GraphQLErrors struct {
+	mu     sync.Mutex
+	errors []GraphQLError
+}
+
+type graphQLErrorsKey struct{}
+
+// WithGraphQLErrors returns a context which records the GraphQL errors of
+// requests made with it.
+func WithGraphQLErrors(ctx context.Context) (context.Context, *GraphQLErrors) {
+	e := &GraphQLErrors{}
+	return context.WithValue(ctx, graphQLErrorsKey{}, e), e
+}
+
+// Errors returns the errors recorded so far.
+func (e *GraphQLErrors) Errors() []GraphQLError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]GraphQLError{}, e.errors...)
+}
+
+// isGraphQLRateLimited reports whether resp is a GraphQL response to req
+// with a RATE_LIMITED error. The body is buffered and restored.
+func isGraphQLRateLimited(req *http.Request, resp *http.Response) bool {
+	if !strings.HasSuffix(req.URL.Path, "/graphql") {
+		return false
+	}
+	for _, e := range readGraphQLErrors(resp) {
+		if e.Type == "RATE_LIMITED" {
+			return true
+		}
+	}
+	return false
+}
+
+// readGraphQLErrors returns the errors in the GraphQL response resp. The
+// body is buffered and restored.
+func readGraphQLErrors(resp *http.Response) []GraphQLError {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var out struct {
+		Errors []GraphQLError `json:"errors"`
+	}
+	if json.Unmarshal(body, &out) != nil {
+		return nil
+	}
+	return out.Errors
+}
+
+// recordGraphQLErrors adds the errors in the GraphQL response resp to the
+// recorder of the request's context, if any. The body is buffered and
+// restored.
+func recordGraphQLErrors(req *http.Request, resp *http.Response) {
+	e, ok := req.Context().Value(graphQLErrorsKey{}).(*GraphQLErrors)
+	if !ok || resp.StatusCode != http.StatusOK || !strings.HasSuffix(req.URL.Path, "/graphql") {
+		return
+	}
+
+	errs := readGraphQLErrors(resp)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errors = append(e.errors, errs...)
+}
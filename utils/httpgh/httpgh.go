@@ -1,19 +1,258 @@
 package httpgh
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/thnxdev/utils/utils/log"
+)
+
+// Transport is a http.RoundTripper for the GitHub APIs. It tracks the rate
+// limit reported by GitHub, waits for the reset once the budget is
+// exhausted and retries requests which were rate limited or, if they are
+// idempotent, failed with a server error.
 type Transport struct {
 	T http.RoundTripper
-}
 
-func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("Accept", "application/vnd.github.hawkgirl-preview+json")
-	return t.T.RoundTrip(req)
+	// MaxRetries is the number of times a failed request is retried.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff used when
+	// GitHub doesn't say how long to wait.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
 }
 
 func NewTransport(T http.RoundTripper) *Transport {
 	if T == nil {
 		T = http.DefaultTransport
 	}
-	return &Transport{T}
+	return &Transport{
+		T:          T,
+		MaxRetries: 8,
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Minute,
+		remaining:  -1,
+	}
+}
+
+// Remaining returns the request budget left in the current rate limit
+// window and when the window resets. remaining is -1 until GitHub has
+// reported a rate limit.
+func (t *Transport) Remaining() (remaining int, reset time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remaining, t.reset
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	logger := log.FromContext(ctx)
+
+	req = req.Clone(ctx)
+	req.Header.Add("Accept", "application/vnd.github.hawkgirl-preview+json")
+
+	idempotent := isIdempotent(req)
+
+	for attempt := 0; ; attempt++ {
+		err := t.waitForReset(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				r.Body, err = req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		var wait time.Duration
+		resp, err := t.T.RoundTrip(r)
+		if err != nil {
+			if !idempotent || !t.canRetry(req, attempt) || ctx.Err() != nil {
+				return nil, err
+			}
+			wait = t.backoff(attempt)
+			logger.WithError(err).Warnf("github request failed, retrying in %s", wait)
+		} else {
+			t.update(resp)
+
+			var retry bool
+			wait, retry = t.retryAfter(req, resp, idempotent, attempt)
+			if !retry || !t.canRetry(req, attempt) {
+				recordGraphQLErrors(req, resp)
+				return resp, nil
+			}
+			logger.Warnf("github responded %s, retrying in %s", resp.Status, wait)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		err = sleep(ctx, wait)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// canRetry reports whether req may be sent again after attempt.
+func (t *Transport) canRetry(req *http.Request, attempt int) bool {
+	if attempt >= t.MaxRetries {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryAfter reports whether resp to req should be retried and how long to
+// wait first. Rate limited requests were rejected before being processed
+// and are always retried; server errors only for idempotent requests.
+// GraphQL reports its primary rate limit with a 200 and a RATE_LIMITED
+// error.
+func (t *Transport) retryAfter(req *http.Request, resp *http.Response, idempotent bool, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden && isRateLimited(resp),
+		resp.StatusCode == http.StatusOK && isGraphQLRateLimited(req, resp):
+		if d, ok := parseRetryAfter(resp); ok {
+			return d + jitter(time.Second), true
+		}
+		if _, reset := t.Remaining(); resp.Header.Get("X-RateLimit-Remaining") == "0" && !reset.IsZero() {
+			return time.Until(reset) + jitter(time.Second), true
+		}
+		return t.backoff(attempt), true
+
+	case resp.StatusCode >= 500 && idempotent:
+		if d, ok := parseRetryAfter(resp); ok {
+			return d + jitter(time.Second), true
+		}
+		return t.backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff returns the exponential backoff for attempt with jitter.
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.MinBackoff
+	for i := 0; i < attempt && d < t.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > t.MaxBackoff {
+		d = t.MaxBackoff
+	}
+	return d/2 + jitter(d/2)
+}
+
+// update records the rate limit reported in resp.
+func (t *Transport) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+}
+
+// waitForReset blocks until the rate limit window resets if the budget of
+// the current window is exhausted.
+func (t *Transport) waitForReset(ctx context.Context) error {
+	remaining, reset := t.Remaining()
+	if remaining != 0 {
+		return nil
+	}
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	wait += jitter(time.Second)
+	log.FromContext(ctx).Warnf("github rate limit exhausted, waiting %s", wait.Round(time.Second))
+	return sleep(ctx, wait)
+}
+
+func isRateLimited(resp *http.Response) bool {
+	return resp.Header.Get("Retry-After") != "" ||
+		resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether req can safely be sent more than once.
+// GraphQL queries are POSTs but idempotent, mutations are not.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		if !strings.HasSuffix(req.URL.Path, "/graphql") || req.GetBody == nil {
+			return false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return false
+		}
+		defer body.Close()
+		var q struct {
+			Query string `json:"query"`
+		}
+		b, err := io.ReadAll(body)
+		if err != nil || json.Unmarshal(b, &q) != nil {
+			return false
+		}
+		return !strings.HasPrefix(strings.TrimSpace(q.Query), "mutation")
+	}
+	return false
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max))) // nolint:gosec
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
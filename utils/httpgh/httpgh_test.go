@@ -0,0 +1,253 @@
+package httpgh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves the responses in order, repeating the last one, and
+// counts the requests it got.
+type fakeAPI struct {
+	responses []func(w http.ResponseWriter)
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	i := f.requests
+	f.requests++
+	f.mu.Unlock()
+	if i >= len(f.responses) {
+		i = len(f.responses) - 1
+	}
+	f.responses[i](w)
+}
+
+func (f *fakeAPI) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func status(code int, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func rateLimitedGraphQL(reset int64) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		_, _ = w.Write([]byte(`{"data":null,"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`))
+	}
+}
+
+func newTestTransport() *Transport {
+	t := NewTransport(nil)
+	t.MinBackoff = time.Millisecond
+	t.MaxBackoff = 10 * time.Millisecond
+	return t
+}
+
+const (
+	graphQLQuery    = `{"query":"query{viewer{login}}"}`
+	graphQLMutation = `{"query":"mutation($input:CreateSponsorshipInput!){createSponsorship(input:$input){clientMutationId}}"}`
+)
+
+func post(ctx context.Context, t *testing.T, tr *Transport, url, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryAfter(t *testing.T) {
+	api := &fakeAPI{responses: []func(http.ResponseWriter){
+		status(http.StatusTooManyRequests, "Retry-After", "1"),
+		status(http.StatusOK),
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := post(context.Background(), t, newTestTransport(), srv.URL, graphQLMutation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || api.count() != 2 {
+		t.Fatalf("expected 200 after 2 requests, got %d after %d", resp.StatusCode, api.count())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait out Retry-After, waited %s", elapsed)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		first func(reset int64) func(http.ResponseWriter)
+	}{
+		{
+			// Rejected for the exhausted budget: retried after the reset.
+			name: "rate limited",
+			first: func(reset int64) func(http.ResponseWriter) {
+				return status(
+					http.StatusForbidden,
+					"X-RateLimit-Remaining", "0",
+					"X-RateLimit-Reset", strconv.FormatInt(reset, 10),
+				)
+			},
+		},
+		{
+			// The last request of the budget succeeded: the next one waits
+			// for the reset before it's sent.
+			name: "exhausted",
+			first: func(reset int64) func(http.ResponseWriter) {
+				return status(
+					http.StatusOK,
+					"X-RateLimit-Remaining", "0",
+					"X-RateLimit-Reset", strconv.FormatInt(reset, 10),
+				)
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			reset := time.Now().Add(2 * time.Second).Unix()
+			api := &fakeAPI{responses: []func(http.ResponseWriter){
+				test.first(reset),
+				status(http.StatusOK, "X-RateLimit-Remaining", "4999", "X-RateLimit-Reset", strconv.FormatInt(reset+3600, 10)),
+			}}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			tr := newTestTransport()
+			for api.count() < 2 {
+				_, err := post(context.Background(), t, tr, srv.URL, graphQLQuery)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if now := time.Now().Unix(); now < reset {
+				t.Fatalf("expected to wait until the reset at %d, it's %d", reset, now)
+			}
+			if remaining, _ := tr.Remaining(); remaining != 4999 {
+				t.Fatalf("expected 4999 requests remaining, got %d", remaining)
+			}
+		})
+	}
+}
+
+func TestGraphQLRateLimited(t *testing.T) {
+	reset := time.Now().Add(2 * time.Second).Unix()
+	api := &fakeAPI{responses: []func(http.ResponseWriter){
+		rateLimitedGraphQL(reset),
+		status(http.StatusOK, "X-RateLimit-Remaining", "4999", "X-RateLimit-Reset", strconv.FormatInt(reset+3600, 10)),
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	// The mutation wasn't processed, so it's retried too.
+	resp, err := post(context.Background(), t, newTestTransport(), srv.URL, graphQLMutation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || api.count() != 2 {
+		t.Fatalf("expected 200 after 2 requests, got %d after %d", resp.StatusCode, api.count())
+	}
+	if now := time.Now().Unix(); now < reset {
+		t.Fatalf("expected to wait until the reset at %d, it's %d", reset, now)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		requests int
+		status   int
+	}{
+		{"query is retried", graphQLQuery, 2, http.StatusOK},
+		{"mutation isn't retried", graphQLMutation, 1, http.StatusBadGateway},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeAPI{responses: []func(http.ResponseWriter){
+				status(http.StatusBadGateway),
+				status(http.StatusOK),
+			}}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			resp, err := post(context.Background(), t, newTestTransport(), srv.URL, test.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.status || api.count() != test.requests {
+				t.Fatalf("expected %d after %d requests, got %d after %d", test.status, test.requests, resp.StatusCode, api.count())
+			}
+		})
+	}
+}
+
+func TestRetryCap(t *testing.T) {
+	api := &fakeAPI{responses: []func(http.ResponseWriter){
+		status(http.StatusInternalServerError),
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	tr := newTestTransport()
+	tr.MaxRetries = 3
+	resp, err := post(context.Background(), t, tr, srv.URL, graphQLQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || api.count() != 4 {
+		t.Fatalf("expected 500 after 4 requests, got %d after %d", resp.StatusCode, api.count())
+	}
+}
+
+func TestCancelDuringSleep(t *testing.T) {
+	api := &fakeAPI{responses: []func(http.ResponseWriter){
+		status(http.StatusTooManyRequests, "Retry-After", "60"),
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := post(ctx, t, newTestTransport(), srv.URL, graphQLQuery)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to cancel the wait, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected to stop waiting when cancelled, waited %s", elapsed)
+	}
+	if api.count() != 1 {
+		t.Fatalf("expected 1 request, got %d", api.count())
+	}
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/errors"
+)
+
+// Account types a rule can match. GitHub reports them as the __typename of
+// a RepositoryOwner.
+const (
+	TypeUser         = "user"
+	TypeOrganization = "organization"
+)
+
+// Rule matches a recipient by login, glob pattern and/or account type. All
+// fields which are set must match.
+type Rule struct {
+	Login   string `json:"login,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (r Rule) Validate() error {
+	if r.Login == "" && r.Pattern == "" && r.Type == "" {
+		return errors.New("rule must set a login, pattern or type")
+	}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return errors.Wrapf(err, "invalid pattern %q", r.Pattern)
+	}
+	if r.Type != "" && r.Type != TypeUser && r.Type != TypeOrganization {
+		return errors.Errorf("invalid type %q, expected %s or %s", r.Type, TypeUser, TypeOrganization)
+	}
+	return nil
+}
+
+func (r Rule) String() string {
+	var parts []string
+	if r.Login != "" {
+		parts = append(parts, "login="+r.Login)
+	}
+	if r.Pattern != "" {
+		parts = append(parts, "pattern="+r.Pattern)
+	}
+	if r.Type != "" {
+		parts = append(parts, "type="+r.Type)
+	}
+	s := strings.Join(parts, " ")
+	if r.Reason != "" {
+		s += fmt.Sprintf(" (%s)", r.Reason)
+	}
+	return s
+}
+
+// matches reports whether the rule matches login of account type typ. An
+// empty typ means the type is unknown, rules on the type then match if
+// unknownType is set.
+func (r Rule) matches(login, typ string, unknownType bool) bool {
+	login = strings.ToLower(login)
+	if r.Login != "" && strings.ToLower(r.Login) != login {
+		return false
+	}
+	if r.Pattern != "" {
+		if ok, _ := path.Match(strings.ToLower(r.Pattern), login); !ok {
+			return false
+		}
+	}
+	if r.Type != "" {
+		if typ == "" {
+			return unknownType
+		}
+		if r.Type != strings.ToLower(typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy decides which recipients may be sponsored. Deny rules take
+// precedence over allow rules. If there are any allow rules only the
+// recipients they match are allowed.
+type Policy struct {
+	Path  string `json:"-"`
+	Allow []Rule `json:"allow,omitempty"`
+	Deny  []Rule `json:"deny,omitempty"`
+}
+
+// Load the policy file at path. A missing file is an empty policy which
+// allows every recipient.
+func Load(path string) (*Policy, error) {
+	p := &Policy{Path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read policy %s", path)
+	}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode policy %s", path)
+	}
+	for _, r := range append(append([]Rule{}, p.Allow...), p.Deny...) {
+		err = r.Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule in policy %s", path)
+		}
+	}
+	return p, nil
+}
+
+// Save writes the policy back to its file.
+func (p *Policy) Save() error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode policy")
+	}
+	err = os.WriteFile(p.Path, append(b, '\n'), 0o600)
+	return errors.Wrapf(err, "failed to write policy %s", p.Path)
+}
+
+// NeedsType reports whether any rule matches on the account type.
+func (p *Policy) NeedsType() bool {
+	for _, r := range append(append([]Rule{}, p.Allow...), p.Deny...) {
+		if r.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports whether login of account type typ may be sponsored and,
+// if not, why. typ may be empty if the type is unknown, in which case
+// rules on the type are ignored and need to be checked again once it is.
+func (p *Policy) Check(login, typ string) (ok bool, reason string) {
+	for _, r := range p.Deny {
+		if r.matches(login, typ, false) {
+			return false, "denied by " + r.String()
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true, ""
+	}
+	for _, r := range p.Allow {
+		if r.matches(login, typ, true) {
+			return true, ""
+		}
+	}
+	return false, "not in allowlist"
+}
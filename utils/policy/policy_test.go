@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		login  string
+		typ    string
+		ok     bool
+		reason string
+	}{
+		{
+			name:  "empty policy allows everyone",
+			login: "alice",
+			typ:   "User",
+			ok:    true,
+		},
+		{
+			name:   "deny by login is case-insensitive",
+			policy: Policy{Deny: []Rule{{Login: "Alice"}}},
+			login:  "alice",
+			reason: "denied by login=Alice",
+		},
+		{
+			name:   "deny by pattern",
+			policy: Policy{Deny: []Rule{{Pattern: "*-bot", Reason: "bots"}}},
+			login:  "Renovate-Bot",
+			reason: "denied by pattern=*-bot (bots)",
+		},
+		{
+			name:   "pattern doesn't match",
+			policy: Policy{Deny: []Rule{{Pattern: "*-bot"}}},
+			login:  "robot",
+			ok:     true,
+		},
+		{
+			name:   "deny by type",
+			policy: Policy{Deny: []Rule{{Type: TypeOrganization}}},
+			login:  "acme",
+			typ:    "Organization",
+			reason: "denied by type=organization",
+		},
+		{
+			name:   "deny by type ignores unknown types",
+			policy: Policy{Deny: []Rule{{Type: TypeOrganization}}},
+			login:  "acme",
+			ok:     true,
+		},
+		{
+			name:   "every field of a rule must match",
+			policy: Policy{Deny: []Rule{{Pattern: "acme*", Type: TypeUser}}},
+			login:  "acme-corp",
+			typ:    "Organization",
+			ok:     true,
+		},
+		{
+			name:   "allowlist only allows what it matches",
+			policy: Policy{Allow: []Rule{{Login: "alice"}}},
+			login:  "bob",
+			reason: "not in allowlist",
+		},
+		{
+			name:   "allowlist match",
+			policy: Policy{Allow: []Rule{{Login: "bob"}, {Pattern: "ali*"}}},
+			login:  "alice",
+			ok:     true,
+		},
+		{
+			name:   "allow by type matches unknown types",
+			policy: Policy{Allow: []Rule{{Type: TypeUser}}},
+			login:  "alice",
+			ok:     true,
+		},
+		{
+			name:   "allow by type",
+			policy: Policy{Allow: []Rule{{Type: TypeUser}}},
+			login:  "acme",
+			typ:    "Organization",
+			reason: "not in allowlist",
+		},
+		{
+			name: "deny wins over allow",
+			policy: Policy{
+				Allow: []Rule{{Pattern: "*"}},
+				Deny:  []Rule{{Login: "alice"}},
+			},
+			login:  "alice",
+			reason: "denied by login=alice",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, reason := test.policy.Check(test.login, test.typ)
+			if ok != test.ok || reason != test.reason {
+				t.Errorf("Check(%q, %q) = %v, %q, want %v, %q", test.login, test.typ, ok, reason, test.ok, test.reason)
+			}
+		})
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		valid bool
+	}{
+		{Rule{Login: "alice"}, true},
+		{Rule{Pattern: "*-bot"}, true},
+		{Rule{Type: TypeOrganization}, true},
+		{Rule{}, false},
+		{Rule{Reason: "no matcher"}, false},
+		{Rule{Pattern: "["}, false},
+		{Rule{Type: "bot"}, false},
+	}
+	for _, test := range tests {
+		if err := test.rule.Validate(); (err == nil) != test.valid {
+			t.Errorf("%#v.Validate() = %v, want valid %v", test.rule, err, test.valid)
+		}
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	dir := t.TempDir()
+
+	p, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Allow) != 0 || len(p.Deny) != 0 {
+		t.Fatalf("missing policy = %+v, want empty", p)
+	}
+
+	path := filepath.Join(dir, "policy.json")
+	want := &Policy{
+		Path:  path,
+		Allow: []Rule{{Type: TypeUser}},
+		Deny:  []Rule{{Login: "acme", Reason: "our own org"}, {Pattern: "*-bot"}},
+	}
+	err = want.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+	if !got.NeedsType() {
+		t.Error("NeedsType() = false, want true")
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	err = os.WriteFile(invalid, []byte(`{"deny": [{"type": "bot"}]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(invalid)
+	if err == nil {
+		t.Error("Load() of an invalid rule succeeded")
+	}
+}
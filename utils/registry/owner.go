@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/alecthomas/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// Owner is the sponsorability of a GitHub account.
+type Owner struct {
+	Typename    string
+	Sponsorable bool
+}
+
+// GetOwner returns whether the GitHub account login has a GitHub Sponsors
+// listing. The zero Owner is returned if there's no such account.
+func GetOwner(ctx context.Context, client *githubv4.Client, login string) (Owner, error) {
+	var q struct {
+		RepositoryOwner *struct {
+			Typename    string `graphql:"__typename"`
+			Sponsorable struct {
+				HasSponsorsListing bool
+			} `graphql:"... on Sponsorable"`
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+	err := client.Query(ctx, &q, map[string]any{
+		"login": githubv4.String(login),
+	})
+	if err != nil {
+		return Owner{}, errors.Wrapf(err, "failed to query owner %s", login)
+	}
+	if q.RepositoryOwner == nil {
+		return Owner{}, nil
+	}
+	return Owner{Typename: q.RepositoryOwner.Typename, Sponsorable: q.RepositoryOwner.Sponsorable.HasSponsorsListing}, nil
+}
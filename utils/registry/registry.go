@@ -0,0 +1,264 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/thnxdev/utils/utils/log"
+)
+
+// Ecosystems a package can be resolved in, named after their package URL
+// (purl) types.
+const (
+	Golang = "golang"
+	Npm    = "npm"
+	Pypi   = "pypi"
+	Cargo  = "cargo"
+	Gem    = "gem"
+)
+
+// Repo is a GitHub repository.
+type Repo struct {
+	Owner string
+	Name  string
+}
+
+var githubRe = regexp.MustCompile(`github\.com[/:]([A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)/([A-Za-z0-9._-]+)`)
+
+// GitHubRepo returns the GitHub repository a url points to, eg.
+// git+https://github.com/owner/repo.git or git@github.com:owner/repo.
+func GitHubRepo(u string) (Repo, bool) {
+	m := githubRe.FindStringSubmatch(u)
+	if m == nil {
+		return Repo{}, false
+	}
+	name := strings.TrimSuffix(m[2], ".git")
+	if name == "" || name == "." || name == ".." || m[1] == "sponsors" || m[1] == "orgs" {
+		return Repo{}, false
+	}
+	return Repo{Owner: m[1], Name: name}, true
+}
+
+// Resolver looks up the source repository of packages in their registry.
+// Lookups are cached for the lifetime of the Resolver and it's safe for
+// concurrent use.
+type Resolver struct {
+	Client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]resolved
+}
+
+type resolved struct {
+	repo Repo
+	ok   bool
+}
+
+func NewResolver(client *http.Client) *Resolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Resolver{Client: client, cache: map[string]resolved{}}
+}
+
+// Resolve returns the GitHub repository of the package. ok is false if the
+// package isn't hosted on GitHub or its registry doesn't say where it is.
+func (r *Resolver) Resolve(ctx context.Context, ecosystem, name string) (repo Repo, ok bool, err error) {
+	key := ecosystem + ":" + name
+	r.mu.Lock()
+	c, cached := r.cache[key]
+	r.mu.Unlock()
+	if cached {
+		return c.repo, c.ok, nil
+	}
+
+	var urls []string
+	switch ecosystem {
+	case Golang:
+		if strings.HasPrefix(name, "github.com/") {
+			urls = []string{name}
+		} else {
+			urls, err = r.goImport(ctx, name)
+		}
+	case Npm:
+		var v struct {
+			Repository json.RawMessage
+			Homepage   string
+		}
+		err = r.get(ctx, "https://registry.npmjs.org/"+strings.Replace(name, "/", "%2F", 1)+"/latest", &v)
+		var repository struct{ URL string }
+		if json.Unmarshal(v.Repository, &repository) != nil {
+			_ = json.Unmarshal(v.Repository, &repository.URL)
+		}
+		urls = []string{npmShorthand(repository.URL), v.Homepage}
+	case Pypi:
+		var v struct {
+			Info struct {
+				ProjectURLs map[string]string `json:"project_urls"`
+				HomePage    string            `json:"home_page"`
+			}
+		}
+		err = r.get(ctx, "https://pypi.org/pypi/"+url.PathEscape(name)+"/json", &v)
+		for _, k := range []string{"Source", "Source Code", "Repository", "Code", "Homepage"} {
+			urls = append(urls, v.Info.ProjectURLs[k])
+		}
+		// Any other project url, in a stable order.
+		keys := make([]string, 0, len(v.Info.ProjectURLs))
+		for k := range v.Info.ProjectURLs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			urls = append(urls, v.Info.ProjectURLs[k])
+		}
+		urls = append(urls, v.Info.HomePage)
+	case Cargo:
+		var v struct {
+			Crate struct {
+				Repository string
+				Homepage   string
+			}
+		}
+		err = r.get(ctx, "https://crates.io/api/v1/crates/"+url.PathEscape(name), &v)
+		urls = []string{v.Crate.Repository, v.Crate.Homepage}
+	case Gem:
+		var v struct {
+			SourceCodeURI string `json:"source_code_uri"`
+			HomepageURI   string `json:"homepage_uri"`
+		}
+		err = r.get(ctx, "https://rubygems.org/api/v1/gems/"+url.PathEscape(name)+".json", &v)
+		urls = []string{v.SourceCodeURI, v.HomepageURI}
+	default:
+		return repo, false, errors.Errorf("unknown ecosystem %q", ecosystem)
+	}
+	if errors.Is(err, errNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return repo, false, errors.Wrapf(err, "failed to resolve %s package %s", ecosystem, name)
+	}
+
+	for _, u := range urls {
+		if repo, ok = GitHubRepo(u); ok {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	r.cache[key] = resolved{repo: repo, ok: ok}
+	r.mu.Unlock()
+	return repo, ok, nil
+}
+
+// ResolveAll calls resolve for each of names, concurrency at a time, and
+// returns the repos by index. Packages which fail to resolve are logged and
+// left as the zero Repo, as are packages without a GitHub repository.
+func ResolveAll(
+	ctx context.Context,
+	names []string,
+	concurrency int,
+	resolve func(ctx context.Context, i int) (Repo, bool, error),
+) ([]Repo, error) {
+	repos := make([]Repo, len(names))
+	wg, wctx := errgroup.WithContext(ctx)
+	wg.SetLimit(concurrency)
+	for i := range names {
+		i := i
+		wg.Go(func() error {
+			r, ok, err := resolve(wctx, i)
+			if err != nil {
+				if wctx.Err() != nil {
+					return wctx.Err()
+				}
+				log.FromContext(ctx).WithError(err).Warnf("failed to resolve %s", names[i])
+				return nil
+			}
+			if ok {
+				repos[i] = r
+			}
+			return nil
+		})
+	}
+	err := wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+var errNotFound = errors.New("not found")
+
+func (r *Resolver) get(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	// crates.io rejects requests without a user agent.
+	req.Header.Set("User-Agent", "mass-gh-sponsor (github.com/thnxdev/utils)")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return errors.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// npmShorthand expands the github:owner/repo and owner/repo shorthands of
+// package.json repository fields.
+func npmShorthand(u string) string {
+	if strings.HasPrefix(u, "github:") {
+		return "github.com/" + strings.TrimPrefix(u, "github:")
+	}
+	if !strings.Contains(u, ":") && strings.Count(u, "/") == 1 {
+		return "github.com/" + u
+	}
+	return u
+}
+
+var goMetaRe = regexp.MustCompile(`<meta\s+name=["']go-(?:import|source)["']\s+content=["']([^"']*)["']`)
+
+// goImport returns the repository urls from the go-import and go-source
+// meta tags served for a Go module path.
+func (r *Resolver) goImport(ctx context.Context, path string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+path+"?go-get=1", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errNotFound
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, m := range goMetaRe.FindAllStringSubmatch(string(b), -1) {
+		urls = append(urls, strings.Fields(m[1])...)
+	}
+	return urls, nil
+}
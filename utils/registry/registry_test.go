@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGitHubRepo(t *testing.T) {
+	tests := []struct {
+		url  string
+		repo Repo
+		ok   bool
+	}{
+		{"https://github.com/owner/repo", Repo{"owner", "repo"}, true},
+		{"git+https://github.com/owner/repo.git", Repo{"owner", "repo"}, true},
+		{"git@github.com:owner/repo", Repo{"owner", "repo"}, true},
+		{"git://github.com/owner/repo.js.git#main", Repo{"owner", "repo.js"}, true},
+		{"https://github.com/owner/repo/tree/main/packages/pkg", Repo{"owner", "repo"}, true},
+		{"github.com/my-org/my_repo", Repo{"my-org", "my_repo"}, true},
+		{"https://github.com/sponsors/owner", Repo{}, false},
+		{"https://github.com/orgs/owner", Repo{}, false},
+		{"https://github.com/owner/..", Repo{}, false},
+		{"https://github.com/owner", Repo{}, false},
+		{"https://github.com/-owner/repo", Repo{}, false},
+		{"https://gitlab.com/owner/repo", Repo{}, false},
+		{"", Repo{}, false},
+	}
+	for _, test := range tests {
+		repo, ok := GitHubRepo(test.url)
+		if repo != test.repo || ok != test.ok {
+			t.Errorf("GitHubRepo(%q) = %+v, %v, want %+v, %v", test.url, repo, ok, test.repo, test.ok)
+		}
+	}
+}
+
+func TestNpmShorthand(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"github:owner/repo", "github.com/owner/repo"},
+		{"owner/repo", "github.com/owner/repo"},
+		{"gitlab:owner/repo", "gitlab:owner/repo"},
+		{"https://github.com/owner/repo", "https://github.com/owner/repo"},
+		{"owner/repo/sub", "owner/repo/sub"},
+		{"repo", "repo"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := npmShorthand(test.url); got != test.want {
+			t.Errorf("npmShorthand(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+// fakeRegistry answers every request with body.
+type fakeRegistry string
+
+func (f fakeRegistry) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(string(f))),
+	}, nil
+}
+
+func TestResolvePypiProjectURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		repo Repo
+	}{
+		{
+			name: "known key wins",
+			body: `{"info": {"project_urls": {
+				"Changelog": "https://github.com/other/changelog",
+				"Source": "https://github.com/owner/repo"
+			}}}`,
+			repo: Repo{"owner", "repo"},
+		},
+		{
+			name: "other keys in order",
+			body: `{"info": {"project_urls": {
+				"Tracker": "https://github.com/owner/tracker",
+				"Bugs": "https://github.com/owner/bugs",
+				"Docs": "https://github.com/owner/docs"
+			}}}`,
+			repo: Repo{"owner", "bugs"},
+		},
+		{
+			name: "home page last",
+			body: `{"info": {
+				"project_urls": {"Docs": "https://example.com"},
+				"home_page": "https://github.com/owner/home"
+			}}`,
+			repo: Repo{"owner", "home"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Repeated as the order of the keys of a map varies.
+			for i := 0; i < 10; i++ {
+				r := NewResolver(&http.Client{Transport: fakeRegistry(test.body)})
+				repo, ok, err := r.Resolve(context.Background(), Pypi, "pkg")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok || repo != test.repo {
+					t.Fatalf("Resolve() = %+v, %v, want %+v", repo, ok, test.repo)
+				}
+			}
+		})
+	}
+}